@@ -0,0 +1,344 @@
+// Package admin provides the HTTP API for inspecting and purging the items stored by a httpcache.CacheHandler.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache"
+)
+
+// Handler is the http.Handler of the admin API:
+//
+//	GET  /entry?url=<url>&method=GET          inspect the stored entry of the url
+//	GET  /entries?prefix=<key-prefix>&limit=N list the stored entries, without the responses
+//	POST /purge?url=<url>&method=GET          purge the stored entries of the url, in all the partitions
+//	POST /purge?prefix=<url-prefix>&method=GET purge all the stored entries with the url prefix
+//	POST /purge?tag=<tag>                     purge all the stored entries with the tag
+//	POST /flush                               purge all the stored entries
+//	GET  /offline                             get whether the cache handler is offline
+//	POST /offline?enabled=true                toggle the offline mode, see httpcache.CacheHandler.SetOffline
+//
+// Listing the entries requires the cache storage to implement the cache.Iterable.
+// Purging by prefix requires the cache storage to implement the cache.KeyLister, without it purging
+// by url only purges the entry without partition. The url prefix is rewritten by the RewriteRequest
+// like the url, so it must be an absolute url when it's set.
+// see the httpcache.CacheHandler.PurgeTag for purging by tag.
+type Handler struct {
+	Cache *httpcache.CacheHandler
+	// RewriteRequest is optional, it's called with the request built from the url parameter before
+	// looking up the key, e.g to rewrite it as the request sent to the upstream by a reverse proxy.
+	RewriteRequest func(req *http.Request) error
+
+	mux *http.ServeMux
+}
+
+// NewHandler will create the admin API handler of the cache handler
+func NewHandler(c *httpcache.CacheHandler) *Handler {
+	h := &Handler{Cache: c}
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("/entry", h.serveEntry)
+	h.mux.HandleFunc("/entries", h.serveEntries)
+	h.mux.HandleFunc("/purge", h.servePurge)
+	h.mux.HandleFunc("/flush", h.serveFlush)
+	h.mux.HandleFunc("/offline", h.serveOffline)
+	return h
+}
+
+// ServeHTTP implements the http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mux.ServeHTTP(w, req)
+}
+
+// EntryResponse is the response of the entry inspection
+type EntryResponse struct {
+	Key                  string      `json:"key"`
+	RequestMethod        string      `json:"requestMethod"`
+	RequestURI           string      `json:"requestUri"`
+	StatusCode           int         `json:"statusCode"`
+	Headers              http.Header `json:"headers"`
+	CachedTime           time.Time   `json:"cachedTime"`
+	ExpiresAt            time.Time   `json:"expiresAt"`
+	AgeSeconds           float64     `json:"ageSeconds"`
+	FreshnessLeftSeconds float64     `json:"freshnessLeftSeconds"` // negative when the entry is stale
+	Size                 int         `json:"size"`                 // the stored size in bytes
+}
+
+// EntriesResponse is the response of the entries listing
+type EntriesResponse struct {
+	Entries []cache.ItemInfo `json:"entries"`
+	Count   int              `json:"count"` // the number of the listed entries
+	Size    int              `json:"size"`  // the total size of the listed entries in bytes
+}
+
+// PurgeResponse is the response of the purge operations
+type PurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+func (h *Handler) serveEntry(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	target, err := h.targetRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entry, err := h.Cache.Lookup(target)
+	if err != nil {
+		if errors.Is(err, cache.ErrStorageInternal) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "entry not found", http.StatusNotFound)
+		return
+	}
+	entry.Response.Body.Close() //nolint
+
+	now := time.Now()
+	writeJSON(w, EntryResponse{
+		Key:                  entry.Key,
+		RequestMethod:        entry.Item.RequestMethod,
+		RequestURI:           entry.Item.RequestURI,
+		StatusCode:           entry.Response.StatusCode,
+		Headers:              entry.Response.Header,
+		CachedTime:           entry.Item.CachedTime,
+		ExpiresAt:            entry.ExpiresAt,
+		AgeSeconds:           entry.Age(now).Seconds(),
+		FreshnessLeftSeconds: entry.FreshnessLeft(now).Seconds(),
+		Size:                 entry.Size(),
+	})
+}
+
+func (h *Handler) serveEntries(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	iterable, ok := h.Cache.CacheInteractor.(cache.Iterable)
+	if !ok {
+		http.Error(w, cache.ErrNotSupported.Error(), http.StatusNotImplemented)
+		return
+	}
+	limit := -1
+	if l := req.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			http.Error(w, "invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	res := EntriesResponse{Entries: []cache.ItemInfo{}}
+	err := iterable.Range(req.Context(), req.URL.Query().Get("prefix"), func(info cache.ItemInfo) bool {
+		if limit >= 0 && res.Count >= limit {
+			return false
+		}
+		res.Entries = append(res.Entries, info)
+		res.Count++
+		res.Size += info.Size
+		return true
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, res)
+}
+
+func (h *Handler) servePurge(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		purged int
+		err    error
+		query  = req.URL.Query()
+	)
+	switch {
+	case query.Get("url") != "":
+		purged, err = h.purgeURL(req)
+	case query.Get("prefix") != "":
+		purged, err = h.purgePrefix(req)
+	case query.Get("tag") != "":
+		purged, err = h.purgeTag(req)
+	default:
+		http.Error(w, "one of url, prefix or tag parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case errors.Is(err, cache.ErrNotSupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	case errors.Is(err, errBadRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, PurgeResponse{Purged: purged})
+	}
+}
+
+func (h *Handler) purgeURL(req *http.Request) (int, error) {
+	target, err := h.targetRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	key := h.Cache.CacheKey(target)
+	keys, err := h.keys(req, key)
+	if errors.Is(err, cache.ErrNotSupported) {
+		// only the entry without partition can be found
+		if _, err = h.Cache.CacheInteractor.Get(key); err != nil {
+			if errors.Is(err, cache.ErrStorageInternal) {
+				return 0, err
+			}
+			return 0, nil
+		}
+		keys, err = []string{key}, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// the key of the url, and its keys suffixed by the partition or the body hash
+	matched := keys[:0]
+	for _, k := range keys {
+		if k == key || strings.HasPrefix(k, key+" ") {
+			matched = append(matched, k)
+		}
+	}
+	return h.deleteKeys(matched)
+}
+
+func (h *Handler) purgePrefix(req *http.Request) (int, error) {
+	// the key is started with the method and the url of the request, see the httpcache.CacheHandler.CacheKey
+	prefix, err := h.targetPrefix(req)
+	if err != nil {
+		return 0, err
+	}
+	keys, err := h.keys(req, methodParam(req)+" "+prefix)
+	if err != nil {
+		return 0, err
+	}
+	return h.deleteKeys(keys)
+}
+
+func (h *Handler) purgeTag(req *http.Request) (int, error) {
+	return h.Cache.PurgeTag(req.Context(), req.URL.Query().Get("tag"))
+}
+
+func (h *Handler) keys(req *http.Request, prefix string) ([]string, error) {
+	lister, ok := h.Cache.CacheInteractor.(cache.KeyLister)
+	if !ok {
+		return nil, cache.ErrNotSupported
+	}
+	return lister.Keys(req.Context(), prefix)
+}
+
+func (h *Handler) deleteKeys(keys []string) (purged int, err error) {
+	for _, key := range keys {
+		if err = h.Cache.CacheInteractor.Delete(key); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (h *Handler) serveFlush(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := h.Cache.CacheInteractor.Flush(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// OfflineResponse is the response of the offline mode endpoint
+type OfflineResponse struct {
+	Offline bool `json:"offline"`
+}
+
+func (h *Handler) serveOffline(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(req.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(w, "invalid enabled parameter", http.StatusBadRequest)
+			return
+		}
+		h.Cache.SetOffline(enabled)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, OfflineResponse{Offline: h.Cache.Offline()})
+}
+
+var errBadRequest = errors.New("invalid url parameter")
+
+// targetRequest will build the request of the url parameter, as it's sent to the cache handler
+func (h *Handler) targetRequest(req *http.Request) (*http.Request, error) {
+	return h.newTargetRequest(req, req.URL.Query().Get("url"))
+}
+
+// targetPrefix will return the url prefix of the keys to purge, it's rewritten like the url of the
+// targetRequest, so the prefix can be the url requested to a reverse proxy
+func (h *Handler) targetPrefix(req *http.Request) (string, error) {
+	prefix := req.URL.Query().Get("prefix")
+	if h.RewriteRequest == nil {
+		return prefix, nil
+	}
+	targetReq, err := h.newTargetRequest(req, prefix)
+	if err != nil {
+		return "", err
+	}
+	return targetReq.URL.String(), nil
+}
+
+func (h *Handler) newTargetRequest(req *http.Request, rawURL string) (*http.Request, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Host == "" {
+		return nil, errBadRequest
+	}
+	targetReq, err := http.NewRequestWithContext(req.Context(), methodParam(req), target.String(), http.NoBody)
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if h.RewriteRequest != nil {
+		if err = h.RewriteRequest(targetReq); err != nil {
+			return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+		}
+	}
+	return targetReq, nil
+}
+
+func methodParam(req *http.Request) string {
+	method := strings.ToUpper(req.URL.Query().Get("method"))
+	if method == "" {
+		return http.MethodGet
+	}
+	return method
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
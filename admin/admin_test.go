@@ -0,0 +1,165 @@
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/admin"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/bxcodec/httpcache/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func newCachedServer(t *testing.T) (*httptest.Server, *http.Client, *httpcache.CacheHandler) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Surrogate-Key", "all "+r.URL.Path)
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	t.Cleanup(upstream.Close)
+
+	client := &http.Client{}
+	handler, err := httpcache.NewWithInmemoryCache(client, true)
+	require.NoError(t, err)
+	return upstream, client, handler
+}
+
+func do(t *testing.T, client *http.Client, method, target string) (int, []byte) {
+	req, err := http.NewRequestWithContext(context.TODO(), method, target, http.NoBody)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, body
+}
+
+func TestEntryAndPurge(t *testing.T) {
+	upstream, client, handler := newCachedServer(t)
+	api := httptest.NewServer(admin.NewHandler(handler))
+	defer api.Close()
+
+	for _, path := range []string{"/a/1", "/a/2", "/b/1"} {
+		status, _ := do(t, client, http.MethodGet, upstream.URL+path)
+		require.Equal(t, http.StatusOK, status)
+	}
+
+	// inspect
+	status, body := do(t, http.DefaultClient, http.MethodGet, api.URL+"/entry?url="+url.QueryEscape(upstream.URL+"/a/1"))
+	require.Equal(t, http.StatusOK, status)
+	var entry admin.EntryResponse
+	require.NoError(t, json.Unmarshal(body, &entry))
+	require.Equal(t, http.StatusOK, entry.StatusCode)
+	require.Equal(t, "max-age=60", entry.Headers.Get("Cache-Control"))
+	require.InDelta(t, 60, entry.FreshnessLeftSeconds, 5)
+	require.Greater(t, entry.Size, 0)
+
+	status, _ = do(t, http.DefaultClient, http.MethodGet, api.URL+"/entry?url="+url.QueryEscape(upstream.URL+"/c"))
+	require.Equal(t, http.StatusNotFound, status)
+
+	// list
+	status, body = do(t, http.DefaultClient, http.MethodGet, api.URL+"/entries?prefix="+url.QueryEscape("GET "+upstream.URL+"/a/"))
+	require.Equal(t, http.StatusOK, status)
+	var entries admin.EntriesResponse
+	require.NoError(t, json.Unmarshal(body, &entries))
+	require.Equal(t, 2, entries.Count)
+	require.Equal(t, entries.Entries[0].Size+entries.Entries[1].Size, entries.Size)
+
+	// purge by exact url
+	status, body = do(t, http.DefaultClient, http.MethodPost, api.URL+"/purge?url="+url.QueryEscape(upstream.URL+"/a/1"))
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"purged":1}`, string(body))
+	status, _ = do(t, http.DefaultClient, http.MethodGet, api.URL+"/entry?url="+url.QueryEscape(upstream.URL+"/a/1"))
+	require.Equal(t, http.StatusNotFound, status)
+
+	// purge by prefix
+	status, body = do(t, http.DefaultClient, http.MethodPost, api.URL+"/purge?prefix="+url.QueryEscape(upstream.URL+"/a/"))
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"purged":1}`, string(body))
+
+	// purge by tag
+	status, body = do(t, http.DefaultClient, http.MethodPost, api.URL+"/purge?tag=/b/1")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"purged":1}`, string(body))
+
+	// nothing left to purge
+	status, body = do(t, http.DefaultClient, http.MethodPost, api.URL+"/purge?url="+url.QueryEscape(upstream.URL+"/a/1"))
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"purged":0}`, string(body))
+
+	status, _ = do(t, http.DefaultClient, http.MethodPost, api.URL+"/flush")
+	require.Equal(t, http.StatusNoContent, status)
+}
+
+func TestPurgeURLPartitions(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer upstream.Close()
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithCacheMode(httpcache.PrivateCache))
+	require.NoError(t, err)
+	api := httptest.NewServer(admin.NewHandler(handler))
+	defer api.Close()
+
+	for _, token := range []string{"", "Bearer a", "Bearer b"} {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, upstream.URL+"/a", http.NoBody)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	do(t, client, http.MethodGet, upstream.URL+"/ab")
+
+	status, body := do(t, http.DefaultClient, http.MethodPost, api.URL+"/purge?url="+url.QueryEscape(upstream.URL+"/a"))
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"purged":3}`, string(body))
+	status, _ = do(t, http.DefaultClient, http.MethodGet, api.URL+"/entry?url="+url.QueryEscape(upstream.URL+"/ab"))
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestPurgePrefixNotSupported(t *testing.T) {
+	handler, err := httpcache.NewCacheHandlerRoundtrip(http.DefaultTransport, true, new(mocks.ICacheInteractor))
+	require.NoError(t, err)
+	api := httptest.NewServer(admin.NewHandler(handler))
+	defer api.Close()
+
+	status, _ := do(t, http.DefaultClient, http.MethodPost, api.URL+"/purge?prefix=http://example.com/")
+	require.Equal(t, http.StatusNotImplemented, status)
+}
+
+func TestOffline(t *testing.T) {
+	upstream, client, handler := newCachedServer(t)
+	api := httptest.NewServer(admin.NewHandler(handler))
+	defer api.Close()
+
+	status, body := do(t, http.DefaultClient, http.MethodPost, api.URL+"/offline?enabled=true")
+	require.Equal(t, http.StatusOK, status)
+	var offline admin.OfflineResponse
+	require.NoError(t, json.Unmarshal(body, &offline))
+	require.True(t, offline.Offline)
+	require.True(t, handler.Offline())
+
+	status, _ = do(t, client, http.MethodGet, upstream.URL+"/a/1")
+	require.Equal(t, http.StatusGatewayTimeout, status)
+
+	status, _ = do(t, http.DefaultClient, http.MethodPost, api.URL+"/offline?enabled=maybe")
+	require.Equal(t, http.StatusBadRequest, status)
+	status, _ = do(t, http.DefaultClient, http.MethodPost, api.URL+"/offline?enabled=false")
+	require.Equal(t, http.StatusOK, status)
+	status, _ = do(t, client, http.MethodGet, upstream.URL+"/a/1")
+	require.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,232 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default async store options, see AsyncStoreOptions
+const (
+	DefaultAsyncStoreQueueSize = 1000
+	DefaultAsyncStoreWorkers   = 4
+)
+
+// ErrStoreQueueFull will throw if the response is dropped because the queue of the async store is full,
+// see WithAsyncStore
+var ErrStoreQueueFull = errors.New("store queue is full")
+
+// AsyncStoreOptions is the configuration of the write-behind store, see WithAsyncStore
+type AsyncStoreOptions struct {
+	// QueueSize is the max number of the responses waiting to be stored, the DefaultAsyncStoreQueueSize if zero
+	QueueSize int `yaml:"queue_size"`
+	// Workers is the number of the goroutines storing the responses, the DefaultAsyncStoreWorkers if zero
+	Workers int `yaml:"workers"`
+}
+
+// AsyncStoreStats is the statistics of the write-behind store
+type AsyncStoreStats struct {
+	Pending int    // The responses waiting in the queue
+	Stored  uint64 // The responses stored by the workers, including the refused ones
+	Dropped uint64 // The responses dropped because the queue is full
+}
+
+// WithAsyncStore will store the responses in the background (write-behind), so a slow cache storage
+// doesn't add to the latency. The response is returned as soon as the upstream sends its headers, its
+// body is copied while it's read by the caller, and it's queued to be stored once it's read entirely.
+// The responses are dropped with the ErrStoreQueueFull when the queue is full. Call Close to drain the queue.
+func WithAsyncStore(opts AsyncStoreOptions) Option {
+	if opts.QueueSize == 0 {
+		opts.QueueSize = DefaultAsyncStoreQueueSize
+	}
+	if opts.Workers == 0 {
+		opts.Workers = DefaultAsyncStoreWorkers
+	}
+	return func(h *CacheHandler) {
+		h.async = &asyncStore{opts: opts}
+	}
+}
+
+type asyncStore struct {
+	opts    AsyncStoreOptions
+	mu      sync.RWMutex
+	closed  bool
+	queue   chan storeJob
+	wg      sync.WaitGroup
+	stored  uint64
+	dropped uint64
+}
+
+// storeJob is a response waiting to be stored
+type storeJob struct {
+	req       *http.Request
+	resp      *http.Response
+	key       string
+	expiresAt time.Time
+}
+
+func (a *asyncStore) validate() error {
+	if a.opts.QueueSize < 0 || a.opts.Workers < 0 {
+		return fmt.Errorf("%w: the async store options must not be negative", ErrInvalidOption)
+	}
+	return nil
+}
+
+// start will start the workers storing the queued responses
+func (a *asyncStore) start(r *CacheHandler) {
+	a.queue = make(chan storeJob, a.opts.QueueSize)
+	for i := 0; i < a.opts.Workers; i++ {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			for job := range a.queue {
+				r.store(job.req, job.resp, job.key, job.expiresAt)
+				atomic.AddUint64(&a.stored, 1)
+			}
+		}()
+	}
+}
+
+// enqueue will queue the response to be stored, ok is false if the queue is closed
+func (a *asyncStore) enqueue(job storeJob) (ok bool, err error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return false, nil
+	}
+	select {
+	case a.queue <- job:
+		return true, nil
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+		return true, ErrStoreQueueFull
+	}
+}
+
+// storeAsync will store the response once its body is read entirely by the caller
+func (r *CacheHandler) storeAsync(req *http.Request, resp *http.Response, key string, expiresAt time.Time) {
+	// the request is usually done when it's stored, e.g the tags are still indexed after it's canceled
+	req = req.Clone(detachedContext{req.Context()})
+	stored := new(http.Response)
+	*stored = *resp
+	stored.Header = resp.Header.Clone()
+	if resp.ContentLength == 0 {
+		// nothing to wait for, the caller may never read the empty body
+		stored.Body = http.NoBody
+		r.enqueueStore(storeJob{req: req, resp: stored, key: key, expiresAt: expiresAt})
+		return
+	}
+	resp.Body = &teeBody{
+		ReadCloser: resp.Body,
+		maxSize:    r.maxResponseSize,
+		done: func(body []byte, tooLarge bool) {
+			if tooLarge {
+				r.emit(Event{Type: EventCacheSkipped, Key: key, Request: req, Err: ErrResponseTooLarge})
+				return
+			}
+			stored.Body = io.NopCloser(bytes.NewReader(body))
+			r.enqueueStore(storeJob{req: req, resp: stored, key: key, expiresAt: expiresAt})
+		},
+	}
+}
+
+// enqueueStore will queue the response to be stored, it's stored right away if the handler is closed
+func (r *CacheHandler) enqueueStore(job storeJob) {
+	ok, err := r.async.enqueue(job)
+	if !ok {
+		r.store(job.req, job.resp, job.key, job.expiresAt)
+		return
+	}
+	if err != nil {
+		r.emit(Event{Type: EventCacheSkipped, Key: job.key, Request: job.req, Err: err})
+	}
+}
+
+// awaitsStoreOutcome will check whether the caller of the request waits the store outcome, e.g the warming
+func awaitsStoreOutcome(req *http.Request) bool {
+	_, ok := req.Context().Value(eventRecorderKey{}).(Observer)
+	return ok
+}
+
+// AsyncStoreStats will return the statistics of the write-behind store, see WithAsyncStore
+func (r *CacheHandler) AsyncStoreStats() AsyncStoreStats {
+	if r.async == nil {
+		return AsyncStoreStats{}
+	}
+	return AsyncStoreStats{
+		Pending: len(r.async.queue),
+		Stored:  atomic.LoadUint64(&r.async.stored),
+		Dropped: atomic.LoadUint64(&r.async.dropped),
+	}
+}
+
+// Close will wait the queued responses to be stored, until the ctx is done. The responses are stored
+// synchronously after it's closed, see WithAsyncStore. It also stops probing the unhealthy storage,
+// which is tried again by the next requests, see WithStorageHealth.
+func (r *CacheHandler) Close(ctx context.Context) error {
+	if r.health != nil {
+		r.health.close()
+	}
+	if r.async == nil {
+		return nil
+	}
+	r.async.mu.Lock()
+	if !r.async.closed {
+		r.async.closed = true
+		close(r.async.queue)
+	}
+	r.async.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		r.async.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// teeBody copies the body while it's read, the copy is passed to the done func when the body is read entirely.
+// The body closed before it's read entirely is never passed.
+type teeBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	maxSize  int64 // The max size of the copy, unlimited if zero
+	tooLarge bool
+	once     sync.Once
+	done     func(body []byte, tooLarge bool)
+}
+
+func (t *teeBody) Read(p []byte) (n int, err error) {
+	n, err = t.ReadCloser.Read(p)
+	if n > 0 && !t.tooLarge {
+		if t.maxSize > 0 && int64(t.buf.Len()+n) > t.maxSize {
+			t.tooLarge = true
+			t.buf = bytes.Buffer{}
+		} else {
+			t.buf.Write(p[:n])
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		t.once.Do(func() {
+			t.done(t.buf.Bytes(), t.tooLarge)
+		})
+	}
+	return n, err
+}
+
+// discardBody will read the body entirely before closing it, e.g so the response is stored by the write-behind store
+func discardBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,159 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingStorage blocks each Set until it's released
+type blockingStorage struct {
+	cache.ICacheInteractor
+	entered chan string
+	release chan struct{}
+}
+
+func (s *blockingStorage) Set(key string, value cache.CachedResponse) error {
+	s.entered <- key
+	<-s.release
+	return s.ICacheInteractor.Set(key, value)
+}
+
+func TestAsyncStore(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	store := &blockingStorage{
+		ICacheInteractor: inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		entered:          make(chan string, 10),
+		release:          make(chan struct{}),
+	}
+	client := &http.Client{}
+	stats := &httpcache.Stats{}
+	handler, err := httpcache.New(client, store, httpcache.WithObserver(stats),
+		httpcache.WithAsyncStore(httpcache.AsyncStoreOptions{QueueSize: 1, Workers: 1}))
+	require.NoError(t, err)
+
+	// the response is returned while the storage is blocked
+	_, body := doGet(t, client, upstream.URL+"/1")
+	require.Equal(t, "/1", body)
+	select {
+	case <-store.entered:
+	case <-time.After(time.Second):
+		t.Fatal("the response is not stored in the background")
+	}
+	doGet(t, client, upstream.URL+"/2") // queued
+	doGet(t, client, upstream.URL+"/3") // dropped
+	require.Equal(t, httpcache.AsyncStoreStats{Pending: 1, Dropped: 1}, handler.AsyncStoreStats())
+	require.EqualValues(t, 1, stats.Count(httpcache.EventCacheSkipped))
+
+	// drain the queue
+	close(store.release)
+	require.NoError(t, handler.Close(context.TODO()))
+	require.Equal(t, httpcache.AsyncStoreStats{Stored: 2, Dropped: 1}, handler.AsyncStoreStats())
+	require.EqualValues(t, 2, stats.Count(httpcache.EventCacheStored))
+	for path, stored := range map[string]bool{"/1": true, "/2": true, "/3": false} {
+		entry, err := handler.Lookup(httptestRequest(t, upstream.URL+path))
+		require.Equal(t, stored, err == nil, path)
+		if err == nil {
+			entry.Response.Body.Close()
+		}
+	}
+
+	// stored synchronously after it's closed
+	doGet(t, client, upstream.URL+"/4")
+	require.EqualValues(t, 3, stats.Count(httpcache.EventCacheStored))
+}
+
+func TestAsyncStoreCloseTimeout(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	store := &blockingStorage{
+		ICacheInteractor: inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		entered:          make(chan string, 10),
+		release:          make(chan struct{}),
+	}
+	defer close(store.release)
+	client := &http.Client{}
+	handler, err := httpcache.New(client, store, httpcache.WithAsyncStore(httpcache.AsyncStoreOptions{}))
+	require.NoError(t, err)
+	doGet(t, client, upstream.URL+"/1")
+	<-store.entered
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	err = handler.Close(ctx)
+	require.True(t, errors.Is(err, context.DeadlineExceeded), err)
+}
+
+// contextTagStorage fails to index the tags once the context is done, like the redis storage
+type contextTagStorage struct {
+	*blockingStorage
+	indexer cache.TagIndexer
+}
+
+func (s contextTagStorage) AddTags(ctx context.Context, key string, tags []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.indexer.AddTags(ctx, key, tags)
+}
+
+func (s contextTagStorage) KeysByTag(ctx context.Context, tag string) ([]string, error) {
+	return s.indexer.KeysByTag(ctx, tag)
+}
+
+func (s contextTagStorage) RemoveTag(ctx context.Context, tag string) error {
+	return s.indexer.RemoveTag(ctx, tag)
+}
+
+func TestAsyncStoreCanceledRequest(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Cache-Tag", "product")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	bounded := inmem.NewBoundedCache(inmem.BoundedCacheOptions{})
+	store := contextTagStorage{
+		blockingStorage: &blockingStorage{
+			ICacheInteractor: bounded,
+			entered:          make(chan string, 10),
+			release:          make(chan struct{}),
+		},
+		indexer: bounded,
+	}
+	client := &http.Client{}
+	handler, err := httpcache.New(client, store,
+		httpcache.WithAsyncStore(httpcache.AsyncStoreOptions{QueueSize: 1, Workers: 1}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL+"/1", http.NoBody)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// the request is canceled before the worker stores its response
+	<-store.entered
+	cancel()
+	close(store.release)
+	require.NoError(t, handler.Close(context.TODO()))
+
+	purged, err := handler.PurgeTag(context.TODO(), "product")
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+}
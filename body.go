@@ -0,0 +1,152 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyKeySize is the max size of the request body hashed into the key
+const DefaultMaxBodyKeySize = 1 << 20
+
+// BodyKeyOptions is the configuration of the request body hashed into the key, see WithBodyKey
+type BodyKeyOptions struct {
+	// CanonicalJSON will canonicalize the JSON body (by the Content-Type) before it's hashed,
+	// so the order of the object keys and the spaces don't matter.
+	CanonicalJSON bool
+	// MaxBodySize is the max size of the hashed body, the DefaultMaxBodyKeySize if zero.
+	// The request with a larger body is never served from nor stored to the cache storage.
+	MaxBodySize int64
+}
+
+// WithBodyKey will add the hash of the request body to the key of the POST requests, so the responses
+// to different payloads never collide. The body is still sent to the upstream. Note the POST responses
+// are only stored when they have an explicit freshness, e.g a max-age (RFC 7231 section 4.3.3).
+func WithBodyKey(opts BodyKeyOptions) Option {
+	return func(h *CacheHandler) {
+		h.bodyKey = &opts
+	}
+}
+
+// bodyHashKey is the context key of the hash of the request body
+type bodyHashKey struct{}
+
+// hashRequestBody will read the body of the request to hash it into the key. The returned request has
+// a replayable copy of the body, ok is false if the body is too large to be hashed.
+func (r *CacheHandler) hashRequestBody(req *http.Request) (_ *http.Request, ok bool, err error) {
+	req, body, ok, err := replayableBody(req, r.bodyKeyOptions().MaxBodySize)
+	if err != nil || !ok {
+		return req, ok, err
+	}
+	return req.WithContext(context.WithValue(req.Context(), bodyHashKey{}, r.hashBody(req.Header, body))), true, nil
+}
+
+// replayableBody will read the body of the request up to the maxSize (DefaultMaxBodyKeySize if zero),
+// the returned request has a replayable copy of the body. If the body is larger, ok is false and the
+// returned request has the whole body to be sent once.
+func replayableBody(req *http.Request, maxSize int64) (_ *http.Request, body []byte, ok bool, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, true, nil
+	}
+	if maxSize == 0 {
+		maxSize = DefaultMaxBodyKeySize
+	}
+	body, err = io.ReadAll(io.LimitReader(req.Body, maxSize+1))
+	if err != nil {
+		req.Body.Close()
+		return nil, nil, false, err
+	}
+	clone := req.Clone(req.Context())
+	if int64(len(body)) > maxSize {
+		clone.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return clone, nil, false, nil
+	}
+	req.Body.Close()
+
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	clone.ContentLength = int64(len(body))
+	return clone, body, true, nil
+}
+
+// bodyHash will return the hash of the request body, or empty if the body is not part of the key
+func (r *CacheHandler) bodyHash(req *http.Request) string {
+	if (r.bodyKey == nil || req.Method != http.MethodPost) && !r.replaying() {
+		return ""
+	}
+	if hash, ok := req.Context().Value(bodyHashKey{}).(string); ok {
+		return hash
+	}
+	// the request is not sent by this handler, e.g the key is looked up by the admin API
+	if req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	raw, err := io.ReadAll(body)
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	return r.hashBody(req.Header, raw)
+}
+
+// hashBody will hash the body, canonicalized if it's a JSON and the CanonicalJSON is enabled
+func (r *CacheHandler) hashBody(header http.Header, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if r.bodyKeyOptions().CanonicalJSON && isJSON(header.Get("Content-Type")) {
+		if canonical, err := canonicalJSON(body); err == nil {
+			body = canonical
+		}
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// bodyKeyOptions will return the options of the request body hashed into the key, the zero options
+// if the body is only hashed because of the replay mode
+func (r *CacheHandler) bodyKeyOptions() BodyKeyOptions {
+	if r.bodyKey == nil {
+		return BodyKeyOptions{}
+	}
+	return *r.bodyKey
+}
+
+// canonicalJSON will re-encode the JSON with the sorted object keys and without spaces
+func canonicalJSON(raw []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// keep the numbers as is, e.g the large integers
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return json.Marshal(v)
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
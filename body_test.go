@@ -0,0 +1,95 @@
+package httpcache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyKey(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		// the body is still sent to the upstream
+		_, _ = io.Copy(w, r.Body)
+	})
+
+	post := func(client *http.Client, body string) string {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, upstream.URL, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(respBody)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		opts         httpcache.BodyKeyOptions
+		bodies       []string
+		expectedHits int64
+	}{
+		{
+			name:         "different payloads",
+			bodies:       []string{`{"q":"a"}`, `{"q":"b"}`, `{"q":"a"}`},
+			expectedHits: 2,
+		},
+		{
+			name:         "without canonical json",
+			bodies:       []string{`{"q":"a","n":1}`, `{ "n": 1, "q": "a" }`},
+			expectedHits: 2,
+		},
+		{
+			name:         "canonical json",
+			opts:         httpcache.BodyKeyOptions{CanonicalJSON: true},
+			bodies:       []string{`{"q":"a","n":1}`, `{ "n": 1, "q": "a" }`, `{"q":"a","n":1.0}`},
+			expectedHits: 2,
+		},
+		{
+			name:         "too large",
+			opts:         httpcache.BodyKeyOptions{MaxBodySize: 4},
+			bodies:       []string{`{"q":"a"}`, `{"q":"a"}`},
+			expectedHits: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt64(&hits, 0)
+			client := &http.Client{}
+			_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+				httpcache.WithBodyKey(tc.opts))
+			require.NoError(t, err)
+			for _, body := range tc.bodies {
+				require.JSONEq(t, body, post(client, body))
+			}
+			require.Equal(t, tc.expectedHits, atomic.LoadInt64(&hits))
+		})
+	}
+}
+
+func TestBodyKeyLookup(t *testing.T) {
+	handler, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithBodyKey(httpcache.BodyKeyOptions{}))
+	require.NoError(t, err)
+
+	newRequest := func(body string) *http.Request {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, "http://example.com/search", strings.NewReader(body))
+		require.NoError(t, err)
+		return req
+	}
+	key := handler.CacheKey(newRequest(`{"q":"a"}`))
+	require.True(t, strings.HasPrefix(key, "POST http://example.com/search body="))
+	require.Equal(t, key, handler.CacheKey(newRequest(`{"q":"a"}`)))
+	require.NotEqual(t, key, handler.CacheKey(newRequest(`{"q":"b"}`)))
+	require.Equal(t, "GET http://example.com/search", handler.CacheKey(httptestRequest(t, "http://example.com/search")))
+}
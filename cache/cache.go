@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"errors"
 	"time"
 )
@@ -14,12 +15,15 @@ var (
 	ErrCacheMissed = errors.New("Cache is missing") //nolint
 	// ErrStorageInternal will throw when some internal error in storage occurred
 	ErrStorageInternal = errors.New("Internal error in storage") //nolint
+	// ErrNotSupported will throw if the operation is not supported by the storage
+	ErrNotSupported = errors.New("Operation is not supported by the storage") //nolint
 )
 
 // Cache storage type
 const (
 	CacheStorageInMemory = "IN-MEMORY"
 	CacheRedis           = "REDIS"
+	CacheStorageDisk     = "DISK"
 	// TODO (bxcodec): Add another storage type
 )
 
@@ -32,12 +36,57 @@ type ICacheInteractor interface {
 	Origin() string
 }
 
+// Pinger is an optional interface for the cache storage that able to check its health,
+// e.g the connection to a remote storage.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// KeyLister is an optional interface for the cache storage that able to list its keys.
+// It's used for the operations that need to find the items, e.g purging by prefix.
+type KeyLister interface {
+	// Keys will return the stored keys that start with the prefix, an empty prefix means all keys
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// ItemInfo is the metadata of a stored item
+type ItemInfo struct {
+	Key        string    `json:"key"`
+	Size       int       `json:"size"`       // The size of the dumped response in bytes
+	CachedTime time.Time `json:"cachedTime"` // The timestamp when the response is cached
+	ExpiresAt  time.Time `json:"expiresAt"`  // The time the storage will evict the item, zero if unknown or never
+}
+
+// Iterable is an optional interface for the cache storage that able to iterate its items
+// without loading the stored responses.
+type Iterable interface {
+	KeyLister
+	// Range will call the fn for each stored item with the key started with the prefix,
+	// the iteration stops when the fn returns false.
+	Range(ctx context.Context, prefix string, fn func(info ItemInfo) bool) error
+}
+
+// TagIndexer is an optional interface for the cache storage that able to index its keys by tag.
+// The tags are taken from the Surrogate-Key and Cache-Tag response headers when the response is stored.
+type TagIndexer interface {
+	// AddTags will associate the key to each of the tags
+	AddTags(ctx context.Context, key string, tags []string) error
+	// KeysByTag will return the keys associated to the tag
+	KeysByTag(ctx context.Context, tag string) ([]string, error)
+	// RemoveTag will remove the tag from the index, the associated items are not removed
+	RemoveTag(ctx context.Context, tag string) error
+}
+
 // CachedResponse represent the cacher struct item
 type CachedResponse struct {
 	DumpedResponse []byte    `json:"response"`      // The dumped response body
 	RequestURI     string    `json:"requestUri"`    // The requestURI of the response
 	RequestMethod  string    `json:"requestMethod"` // The HTTP Method that call the request for this response
 	CachedTime     time.Time `json:"cachedTime"`    // The timestamp when this response is Cached
+	// The time this response become stale, zero means it's calculated from the response headers
+	ExpiresAt time.Time `json:"expiresAt"`
+	// The tags of the request, in addition to the Surrogate-Key and Cache-Tag of the response
+	Tags []string `json:"tags,omitempty"`
 }
 
 // Validate will validate the cached response
@@ -0,0 +1,202 @@
+package disk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bxcodec/httpcache/cache"
+)
+
+const fileExtension = ".json"
+
+type diskCache struct {
+	dir        string
+	expiryTime time.Duration
+}
+
+// entry is the structure stored in each file, the key is kept to identify the file content
+type entry struct {
+	Key      string               `json:"key"`
+	Response cache.CachedResponse `json:"value"`
+}
+
+// NewCache will return the disk cache handler. Each item is stored as a file inside the dir.
+// If the exptime is zero, the item will never expire.
+func NewCache(dir string, exptime time.Duration) cache.ICacheInteractor {
+	return &diskCache{
+		dir:        dir,
+		expiryTime: exptime,
+	}
+}
+
+func (d *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+fileExtension)
+}
+
+func (d *diskCache) Set(key string, value cache.CachedResponse) (err error) { //nolint
+	valueJSON, err := json.Marshal(entry{Key: key, Response: value})
+	if err != nil {
+		return cache.ErrFailedToSaveToCache
+	}
+	if err = os.MkdirAll(d.dir, 0o750); err != nil {
+		return cache.ErrStorageInternal
+	}
+
+	// write to a temporary file first, so the readers never see a partially written item
+	tmp, err := os.CreateTemp(d.dir, "tmp-*")
+	if err != nil {
+		return cache.ErrStorageInternal
+	}
+	defer os.Remove(tmp.Name()) //nolint
+	if _, err = tmp.Write(valueJSON); err != nil {
+		tmp.Close() //nolint
+		return cache.ErrStorageInternal
+	}
+	if err = tmp.Close(); err != nil {
+		return cache.ErrStorageInternal
+	}
+	if err = os.Rename(tmp.Name(), d.path(key)); err != nil {
+		return cache.ErrStorageInternal
+	}
+	return nil
+}
+
+func (d *diskCache) Get(key string) (res cache.CachedResponse, err error) {
+	item, err := d.read(d.path(key))
+	if err != nil {
+		return
+	}
+	if item.Key != key {
+		return cache.CachedResponse{}, cache.ErrCacheMissed
+	}
+	if d.expired(item.Response) {
+		_ = d.Delete(key)
+		return cache.CachedResponse{}, cache.ErrCacheMissed
+	}
+	return item.Response, nil
+}
+
+func (d *diskCache) read(path string) (item entry, err error) {
+	valueJSON, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return entry{}, cache.ErrCacheMissed
+		}
+		return entry{}, cache.ErrStorageInternal
+	}
+	if err = json.Unmarshal(valueJSON, &item); err != nil {
+		return entry{}, cache.ErrStorageInternal
+	}
+	return item, nil
+}
+
+func (d *diskCache) expired(res cache.CachedResponse) bool {
+	return d.expiryTime > 0 && time.Since(res.CachedTime) > d.expiryTime
+}
+
+func (d *diskCache) Delete(key string) (err error) {
+	err = os.Remove(d.path(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cache.ErrStorageInternal
+	}
+	return nil
+}
+
+// Ping will check the directory is usable for storing the items
+func (d *diskCache) Ping(_ context.Context) error {
+	if err := os.MkdirAll(d.dir, 0o750); err != nil {
+		return fmt.Errorf("%w: %v", cache.ErrStorageInternal, err)
+	}
+	tmp, err := os.CreateTemp(d.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("%w: %v", cache.ErrStorageInternal, err)
+	}
+	tmp.Close()           //nolint
+	os.Remove(tmp.Name()) //nolint
+	return nil
+}
+
+func (d *diskCache) Origin() string {
+	return cache.CacheStorageDisk
+}
+
+func (d *diskCache) Flush() error {
+	files, err := d.files()
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		err = os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return cache.ErrStorageInternal
+		}
+	}
+	return nil
+}
+
+// files will return the path of all the stored items
+func (d *diskCache) files() (paths []string, err error) {
+	files, err := os.ReadDir(d.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, cache.ErrStorageInternal
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileExtension) {
+			continue
+		}
+		paths = append(paths, filepath.Join(d.dir, f.Name()))
+	}
+	return paths, nil
+}
+
+func (d *diskCache) Keys(ctx context.Context, prefix string) (keys []string, err error) {
+	err = d.Range(ctx, prefix, func(info cache.ItemInfo) bool {
+		keys = append(keys, info.Key)
+		return true
+	})
+	return keys, err
+}
+
+func (d *diskCache) Range(ctx context.Context, prefix string, fn func(info cache.ItemInfo) bool) error {
+	files, err := d.files()
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		item, errRead := d.read(path)
+		if errRead != nil {
+			// the file might be removed in the meantime
+			continue
+		}
+		if !strings.HasPrefix(item.Key, prefix) || d.expired(item.Response) {
+			continue
+		}
+		info := cache.ItemInfo{
+			Key:        item.Key,
+			Size:       len(item.Response.DumpedResponse),
+			CachedTime: item.Response.CachedTime,
+		}
+		if d.expiryTime > 0 {
+			info.ExpiresAt = item.Response.CachedTime.Add(d.expiryTime)
+		}
+		if !fn(info) {
+			return nil
+		}
+	}
+	return nil
+}
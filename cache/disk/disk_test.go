@@ -0,0 +1,78 @@
+package disk_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache/cache"
+	"github.com/bxcodec/httpcache/cache/disk"
+)
+
+func TestCacheDisk(t *testing.T) {
+	cacheObj := disk.NewCache(t.TempDir(), time.Minute)
+	testKey := "KEY"
+	testVal := cache.CachedResponse{
+		DumpedResponse: []byte("HTTP/1.1 200 OK\r\n\r\n"),
+		RequestURI:     "http://bxcodec.io",
+		RequestMethod:  "GET",
+		CachedTime:     time.Now(),
+	}
+
+	// Try to SET item
+	err := cacheObj.Set(testKey, testVal)
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+
+	// try to GET item from cache
+	res, err := cacheObj.Get(testKey)
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	// assert the content
+	if res.RequestURI != testVal.RequestURI {
+		t.Fatalf("expected %v, got %v", testVal.RequestURI, res.RequestURI)
+	}
+	// assert the content
+	if string(res.DumpedResponse) != string(testVal.DumpedResponse) {
+		t.Fatalf("expected %s, got %s", testVal.DumpedResponse, res.DumpedResponse)
+	}
+
+	// try to DELETE the item
+	err = cacheObj.Delete(testKey)
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+
+	// try to re-GET item from cache after deleted
+	_, err = cacheObj.Get(testKey)
+	if err != cache.ErrCacheMissed {
+		t.Fatalf("expected %v, got %v", cache.ErrCacheMissed, err)
+	}
+}
+
+func TestCacheDiskExpired(t *testing.T) {
+	cacheObj := disk.NewCache(t.TempDir(), time.Minute)
+	testVal := cache.CachedResponse{
+		DumpedResponse: []byte("HTTP/1.1 200 OK\r\n\r\n"),
+		RequestURI:     "http://bxcodec.io",
+		RequestMethod:  "GET",
+		CachedTime:     time.Now().Add(-time.Hour),
+	}
+
+	err := cacheObj.Set("KEY", testVal)
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+
+	_, err = cacheObj.Get("KEY")
+	if err != cache.ErrCacheMissed {
+		t.Fatalf("expected %v, got %v", cache.ErrCacheMissed, err)
+	}
+
+	// flushing an empty storage should be fine
+	err = cacheObj.Flush()
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+}
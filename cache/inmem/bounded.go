@@ -0,0 +1,303 @@
+package inmem
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bxcodec/httpcache/cache"
+)
+
+// Eviction policies of the BoundedCache
+const (
+	// PolicyLRU evicts the least recently used items
+	PolicyLRU = "lru"
+	// PolicyTinyLFU evicts the least recently used items, but only admits a new item
+	// if it's accessed more frequently than the items it would evict.
+	PolicyTinyLFU = "tinylfu"
+)
+
+// DefaultMaxBytes is the max total size of the BoundedCache if it's not set
+const DefaultMaxBytes = 64 * 1024 * 1024
+
+// BoundedCacheOptions for the memory-bounded in-memory cache
+type BoundedCacheOptions struct {
+	MaxBytes     int64         // The max total size of the stored items in bytes, DefaultMaxBytes if not set
+	MaxItemBytes int64         // Optional, the item larger than it is never stored
+	ExpiryTime   time.Duration // Optional, the max lifetime of an item, zero means never expire
+	Policy       string        // The eviction policy, PolicyLRU (default) or PolicyTinyLFU
+}
+
+// BoundedStats represent the current state of the BoundedCache, used for monitoring
+type BoundedStats struct {
+	Size       int64  // The total size of the stored items in bytes
+	MaxBytes   int64  // The max total size of the stored items in bytes
+	Items      int    // The number of the stored items
+	Evictions  uint64 // The number of items evicted to free up space
+	Rejections uint64 // The number of items not stored due to its size or the admission policy
+}
+
+// BoundedCache is an in-memory cache bounded by the total size of the stored items instead
+// of the number of items. The size of an item is the length of its key and dumped response.
+type BoundedCache struct {
+	mutex   sync.Mutex
+	options BoundedCacheOptions
+	items   map[string]*list.Element
+	lru     *list.List // the front is the most recently used
+	size    int64
+	sketch  *frequencySketch // only for PolicyTinyLFU
+	tags    *tagIndex
+
+	evictions  uint64
+	rejections uint64
+}
+
+type boundedItem struct {
+	key   string
+	value cache.CachedResponse
+	size  int64
+}
+
+const (
+	// averageItemBytes is used to estimate the number of items of the frequency sketch
+	averageItemBytes = 4 * 1024
+	minSketchItems   = 1024
+)
+
+// NewBoundedCache will return the memory-bounded in-memory cache handler
+func NewBoundedCache(options BoundedCacheOptions) *BoundedCache {
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultMaxBytes
+	}
+	c := &BoundedCache{
+		options: options,
+		items:   map[string]*list.Element{},
+		lru:     list.New(),
+		tags:    newTagIndex(),
+	}
+	if options.Policy == PolicyTinyLFU {
+		estimatedItems := options.MaxBytes / averageItemBytes
+		if estimatedItems < minSketchItems {
+			estimatedItems = minSketchItems
+		}
+		c.sketch = newFrequencySketch(int(estimatedItems))
+	}
+	return c
+}
+
+// Set will store the item, the cache.ErrFailedToSaveToCache is returned if the item is not stored
+// due to its size or the admission policy.
+func (c *BoundedCache) Set(key string, value cache.CachedResponse) error { //nolint
+	size := int64(len(key) + len(value.DumpedResponse))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.sketch != nil {
+		c.sketch.increment(key)
+	}
+
+	if size > c.options.MaxBytes || (c.options.MaxItemBytes > 0 && size > c.options.MaxItemBytes) {
+		c.rejections++
+		return cache.ErrFailedToSaveToCache
+	}
+
+	// the existing item is replaced only once the new value is admitted
+	existing := c.items[key]
+	victims := c.victims(size, existing)
+	if c.sketch != nil && !c.admit(key, victims) {
+		c.rejections++
+		return cache.ErrFailedToSaveToCache
+	}
+	if existing != nil {
+		c.removeElement(existing)
+	}
+	for _, elem := range victims {
+		c.removeElement(elem)
+		c.evictions++
+	}
+
+	c.items[key] = c.lru.PushFront(&boundedItem{key: key, value: value, size: size})
+	c.size += size
+	return nil
+}
+
+// victims will return the least recently used items need to be evicted to store an item of the size,
+// the existing item replaced by it is never a victim
+func (c *BoundedCache) victims(size int64, existing *list.Element) (victims []*list.Element) {
+	freed := int64(0)
+	if existing != nil {
+		freed = existing.Value.(*boundedItem).size
+	}
+	for elem := c.lru.Back(); elem != nil && c.size-freed+size > c.options.MaxBytes; elem = elem.Prev() {
+		if elem == existing {
+			continue
+		}
+		victims = append(victims, elem)
+		freed += elem.Value.(*boundedItem).size
+	}
+	return victims
+}
+
+// admit will decide whether the candidate is more valuable than the victims based on the access frequency
+func (c *BoundedCache) admit(candidate string, victims []*list.Element) bool {
+	candidateFreq := c.sketch.estimate(candidate)
+	for _, elem := range victims {
+		if c.sketch.estimate(elem.Value.(*boundedItem).key) >= candidateFreq {
+			return false
+		}
+	}
+	return true
+}
+
+// Get will retrieve the item, the expired item is removed
+func (c *BoundedCache) Get(key string) (res cache.CachedResponse, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.sketch != nil {
+		c.sketch.increment(key)
+	}
+
+	elem, ok := c.items[key]
+	if !ok {
+		return cache.CachedResponse{}, cache.ErrCacheMissed
+	}
+	item := elem.Value.(*boundedItem)
+	if c.expired(item) {
+		c.removeElement(elem)
+		return cache.CachedResponse{}, cache.ErrCacheMissed
+	}
+	c.lru.MoveToFront(elem)
+	return item.value, nil
+}
+
+func (c *BoundedCache) expired(item *boundedItem) bool {
+	return c.options.ExpiryTime > 0 && time.Since(item.value.CachedTime) > c.options.ExpiryTime
+}
+
+// Delete will remove the item
+func (c *BoundedCache) Delete(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+	return nil
+}
+
+func (c *BoundedCache) removeElement(elem *list.Element) {
+	item := elem.Value.(*boundedItem)
+	c.lru.Remove(elem)
+	delete(c.items, item.key)
+	c.size -= item.size
+	c.tags.removeKey(item.key)
+}
+
+// Flush will remove all the items
+func (c *BoundedCache) Flush() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = map[string]*list.Element{}
+	c.lru.Init()
+	c.size = 0
+	c.tags.clear()
+	return nil
+}
+
+// Origin will return the storage type
+func (c *BoundedCache) Origin() string {
+	return cache.CacheStorageInMemory
+}
+
+// Stats will return the current state of the cache
+func (c *BoundedCache) Stats() BoundedStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return BoundedStats{
+		Size:       c.size,
+		MaxBytes:   c.options.MaxBytes,
+		Items:      len(c.items),
+		Evictions:  c.evictions,
+		Rejections: c.rejections,
+	}
+}
+
+// Keys will return the keys started with the prefix
+func (c *BoundedCache) Keys(ctx context.Context, prefix string) (keys []string, err error) {
+	err = c.Range(ctx, prefix, func(info cache.ItemInfo) bool {
+		keys = append(keys, info.Key)
+		return true
+	})
+	return keys, err
+}
+
+// Range will iterate the items started with the prefix, without updating their recent-ness
+func (c *BoundedCache) Range(ctx context.Context, prefix string, fn func(info cache.ItemInfo) bool) error {
+	c.mutex.Lock()
+	infos := make([]cache.ItemInfo, 0, len(c.items))
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		item := elem.Value.(*boundedItem)
+		if !strings.HasPrefix(item.key, prefix) || c.expired(item) {
+			continue
+		}
+		info := cache.ItemInfo{
+			Key:        item.key,
+			Size:       len(item.value.DumpedResponse),
+			CachedTime: item.value.CachedTime,
+		}
+		if c.options.ExpiryTime > 0 {
+			info.ExpiresAt = item.value.CachedTime.Add(c.options.ExpiryTime)
+		}
+		infos = append(infos, info)
+	}
+	c.mutex.Unlock()
+
+	// the fn is called without holding the lock, so it's safe to call the other methods
+	for _, info := range infos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(info) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// AddTags will associate the key to the tags
+func (c *BoundedCache) AddTags(_ context.Context, key string, tags []string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// the item might be evicted already
+	if _, ok := c.items[key]; ok {
+		c.tags.add(key, tags)
+	}
+	return nil
+}
+
+// KeysByTag will return the keys associated to the tag, the expired keys are removed
+func (c *BoundedCache) KeysByTag(_ context.Context, tag string) ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	keys := c.tags.keys(tag)
+	stored := keys[:0]
+	for _, key := range keys {
+		elem, ok := c.items[key]
+		if !ok {
+			continue
+		}
+		if c.expired(elem.Value.(*boundedItem)) {
+			c.removeElement(elem)
+			continue
+		}
+		stored = append(stored, key)
+	}
+	return stored, nil
+}
+
+// RemoveTag will remove the tag from the index
+func (c *BoundedCache) RemoveTag(_ context.Context, tag string) error {
+	c.tags.removeTag(tag)
+	return nil
+}
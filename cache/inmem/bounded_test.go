@@ -0,0 +1,138 @@
+package inmem_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache/cache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+)
+
+func boundedItem(size int) cache.CachedResponse {
+	return cache.CachedResponse{
+		DumpedResponse: bytes.Repeat([]byte("x"), size),
+		RequestURI:     "http://bxcodec.io",
+		RequestMethod:  "GET",
+		CachedTime:     time.Now(),
+	}
+}
+
+func TestBoundedCacheLRU(t *testing.T) {
+	// the key length is counted, each item below is 100 bytes
+	c := inmem.NewBoundedCache(inmem.BoundedCacheOptions{MaxBytes: 300, MaxItemBytes: 150})
+	for i := 0; i < 3; i++ {
+		if err := c.Set(fmt.Sprintf("KEY-%d", i), boundedItem(95)); err != nil {
+			t.Fatalf("expected %v, got %v", nil, err)
+		}
+	}
+	// KEY-0 become the most recently used
+	if _, err := c.Get("KEY-0"); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if err := c.Set("KEY-3", boundedItem(95)); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if _, err := c.Get("KEY-1"); err != cache.ErrCacheMissed {
+		t.Fatalf("expected %v, got %v", cache.ErrCacheMissed, err)
+	}
+	if _, err := c.Get("KEY-0"); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+
+	// larger than the max item size
+	if err := c.Set("KEY-4", boundedItem(200)); err != cache.ErrFailedToSaveToCache {
+		t.Fatalf("expected %v, got %v", cache.ErrFailedToSaveToCache, err)
+	}
+
+	stats := c.Stats()
+	if stats.Size != 300 || stats.Items != 3 || stats.Evictions != 1 || stats.Rejections != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if err := c.Delete("KEY-0"); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if stats = c.Stats(); stats.Size != 200 {
+		t.Fatalf("expected %v, got %v", 200, stats.Size)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if stats = c.Stats(); stats.Size != 0 || stats.Items != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestBoundedCacheRejectedUpdate(t *testing.T) {
+	c := inmem.NewBoundedCache(inmem.BoundedCacheOptions{MaxBytes: 300, MaxItemBytes: 150})
+	for i := 0; i < 3; i++ {
+		if err := c.Set(fmt.Sprintf("KEY-%d", i), boundedItem(95)); err != nil {
+			t.Fatalf("expected %v, got %v", nil, err)
+		}
+	}
+
+	// the oversized update keeps the current item
+	if err := c.Set("KEY-0", boundedItem(200)); err != cache.ErrFailedToSaveToCache {
+		t.Fatalf("expected %v, got %v", cache.ErrFailedToSaveToCache, err)
+	}
+	if _, err := c.Get("KEY-0"); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+
+	// the update replaces the item without evicting the others
+	if err := c.Set("KEY-0", boundedItem(90)); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if stats := c.Stats(); stats.Size != 295 || stats.Items != 3 || stats.Evictions != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestBoundedCacheTinyLFU(t *testing.T) {
+	c := inmem.NewBoundedCache(inmem.BoundedCacheOptions{MaxBytes: 200, Policy: inmem.PolicyTinyLFU})
+	for _, key := range []string{"HOT-0", "HOT-1"} {
+		if err := c.Set(key, boundedItem(95)); err != nil {
+			t.Fatalf("expected %v, got %v", nil, err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := c.Get(key); err != nil {
+				t.Fatalf("expected %v, got %v", nil, err)
+			}
+		}
+	}
+
+	// a one-hit item must not evict the frequently accessed ones
+	if err := c.Set("COLD", boundedItem(95)); err != cache.ErrFailedToSaveToCache {
+		t.Fatalf("expected %v, got %v", cache.ErrFailedToSaveToCache, err)
+	}
+	for _, key := range []string{"HOT-0", "HOT-1"} {
+		if _, err := c.Get(key); err != nil {
+			t.Fatalf("expected %v, got %v", nil, err)
+		}
+	}
+
+	// once it's requested frequently enough, it's admitted
+	for i := 0; i < 10; i++ {
+		_, _ = c.Get("COLD")
+	}
+	if err := c.Set("COLD", boundedItem(95)); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if stats := c.Stats(); stats.Evictions != 1 || stats.Rejections != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestBoundedCacheExpired(t *testing.T) {
+	c := inmem.NewBoundedCache(inmem.BoundedCacheOptions{ExpiryTime: time.Minute})
+	item := boundedItem(10)
+	item.CachedTime = time.Now().Add(-time.Hour)
+	if err := c.Set("KEY", item); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if _, err := c.Get("KEY"); err != cache.ErrCacheMissed {
+		t.Fatalf("expected %v, got %v", cache.ErrCacheMissed, err)
+	}
+}
@@ -1,29 +1,39 @@
 package inmem
 
 import (
+	"context"
+	"strings"
+	"sync"
+
 	memcache "github.com/bxcodec/gotcha/cache"
 	"github.com/bxcodec/httpcache/cache"
 )
 
 type inmemCache struct {
 	cache memcache.Cache
+	tags  *tagIndex
 }
 
 // NewCache will return the inmemory cache handler
 func NewCache(c memcache.Cache) cache.ICacheInteractor {
 	return &inmemCache{
 		cache: c,
+		tags:  newTagIndex(),
 	}
 }
 
 func (i *inmemCache) Set(key string,
 	value cache.CachedResponse) (err error) { //nolint
+	// the tags of the overwritten response are never the tags of the new one, they're added by AddTags
+	i.tags.removeKey(key)
 	return i.cache.Set(key, value)
 }
 
 func (i *inmemCache) Get(key string) (res cache.CachedResponse, err error) {
 	item, err := i.cache.Get(key)
 	if err != nil {
+		// evicted or expired, the index is never told by the storage
+		i.tags.removeKey(key)
 		return
 	}
 	res = item.(cache.CachedResponse)
@@ -31,6 +41,7 @@ func (i *inmemCache) Get(key string) (res cache.CachedResponse, err error) {
 }
 
 func (i *inmemCache) Delete(key string) (err error) {
+	i.tags.removeKey(key)
 	return i.cache.Delete(key)
 }
 
@@ -39,5 +50,172 @@ func (i *inmemCache) Origin() string {
 }
 
 func (i *inmemCache) Flush() error {
+	i.tags.clear()
 	return i.cache.ClearCache()
 }
+
+func (i *inmemCache) Keys(_ context.Context, prefix string) (keys []string, err error) {
+	all, err := i.cache.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range all {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (i *inmemCache) Range(ctx context.Context, prefix string, fn func(info cache.ItemInfo) bool) error {
+	keys, err := i.Keys(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		res, err := i.Get(key)
+		if err != nil {
+			// expired or evicted in the meantime
+			continue
+		}
+		info := cache.ItemInfo{
+			Key:        key,
+			Size:       len(res.DumpedResponse),
+			CachedTime: res.CachedTime,
+		}
+		if !fn(info) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (i *inmemCache) AddTags(_ context.Context, key string, tags []string) error {
+	if i.tags.add(key, tags) {
+		return i.pruneTags()
+	}
+	return nil
+}
+
+// KeysByTag will return the stored keys associated to the tag, the evicted keys are removed from the index
+func (i *inmemCache) KeysByTag(_ context.Context, tag string) ([]string, error) {
+	if err := i.pruneTags(); err != nil {
+		return nil, err
+	}
+	return i.tags.keys(tag), nil
+}
+
+// pruneTags will remove the keys evicted or expired by the storage from the tag index
+func (i *inmemCache) pruneTags() error {
+	keys, err := i.cache.GetKeys()
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		stored[key] = struct{}{}
+	}
+	i.tags.retain(stored)
+	return nil
+}
+
+func (i *inmemCache) RemoveTag(_ context.Context, tag string) error {
+	i.tags.removeTag(tag)
+	return nil
+}
+
+// minPruneSize is the number of the indexed keys from which the index is pruned while adding the tags
+const minPruneSize = 1024
+
+// tagIndex is the in-memory index of the keys by tag
+type tagIndex struct {
+	mutex     sync.RWMutex
+	byTag     map[string]map[string]struct{}
+	byKeys    map[string][]string
+	pruneSize int // The number of the indexed keys from which the index should be pruned
+}
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{
+		byTag:     map[string]map[string]struct{}{},
+		byKeys:    map[string][]string{},
+		pruneSize: minPruneSize,
+	}
+}
+
+// add will index the key by the tags, prune is true if the index has grown enough to be pruned.
+// The index is pruned at twice its size after the previous pruning, so it's amortized.
+func (t *tagIndex) add(key string, tags []string) (prune bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.removeKeyLocked(key)
+	for _, tag := range tags {
+		keys, ok := t.byTag[tag]
+		if !ok {
+			keys = map[string]struct{}{}
+			t.byTag[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	t.byKeys[key] = tags
+	return len(t.byKeys) >= t.pruneSize
+}
+
+// retain will remove the keys not stored anymore
+func (t *tagIndex) retain(stored map[string]struct{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for key := range t.byKeys {
+		if _, ok := stored[key]; !ok {
+			t.removeKeyLocked(key)
+		}
+	}
+	t.pruneSize = 2 * len(t.byKeys)
+	if t.pruneSize < minPruneSize {
+		t.pruneSize = minPruneSize
+	}
+}
+
+func (t *tagIndex) keys(tag string) (keys []string) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	for key := range t.byTag[tag] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (t *tagIndex) removeTag(tag string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for key := range t.byTag[tag] {
+		t.removeKeyLocked(key)
+	}
+	delete(t.byTag, tag)
+}
+
+func (t *tagIndex) removeKey(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.removeKeyLocked(key)
+}
+
+func (t *tagIndex) removeKeyLocked(key string) {
+	for _, tag := range t.byKeys[key] {
+		delete(t.byTag[tag], key)
+		if len(t.byTag[tag]) == 0 {
+			delete(t.byTag, tag)
+		}
+	}
+	delete(t.byKeys, key)
+}
+
+func (t *tagIndex) clear() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.byTag = map[string]map[string]struct{}{}
+	t.byKeys = map[string][]string{}
+}
@@ -1,6 +1,7 @@
 package inmem_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -57,3 +58,89 @@ func TestCacheInMemory(t *testing.T) {
 		t.Fatalf("expected %v, got %v", err, nil)
 	}
 }
+
+func TestCacheInMemoryRange(t *testing.T) {
+	c := gotcha.New(
+		gotcha.NewOption().SetAlgorithm(inmemcache.LRUAlgorithm).
+			SetExpiryTime(time.Minute).SetMaxSizeItem(100),
+	)
+	cacheObj := inmem.NewCache(c)
+	for _, key := range []string{"GET http://a/1", "GET http://a/2", "GET http://b/1"} {
+		err := cacheObj.Set(key, cache.CachedResponse{
+			DumpedResponse: []byte(key),
+			RequestURI:     key,
+			RequestMethod:  "GET",
+			CachedTime:     time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("expected %v, got %v", nil, err)
+		}
+	}
+
+	size := 0
+	err := cacheObj.(cache.Iterable).Range(context.Background(), "GET http://a/", func(info cache.ItemInfo) bool {
+		size += info.Size
+		return true
+	})
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if size != 2*len("GET http://a/1") {
+		t.Fatalf("expected %v, got %v", 2*len("GET http://a/1"), size)
+	}
+}
+
+func TestCacheInMemoryTagsEvicted(t *testing.T) {
+	c := gotcha.New(
+		gotcha.NewOption().SetAlgorithm(inmemcache.LRUAlgorithm).
+			SetExpiryTime(time.Minute).SetMaxSizeItem(1),
+	)
+	cacheObj := inmem.NewCache(c)
+	indexer := cacheObj.(cache.TagIndexer)
+	ctx := context.Background()
+	for _, key := range []string{"KEY-1", "KEY-2"} {
+		if err := cacheObj.Set(key, cache.CachedResponse{CachedTime: time.Now()}); err != nil {
+			t.Fatalf("expected %v, got %v", nil, err)
+		}
+		if err := indexer.AddTags(ctx, key, []string{"product"}); err != nil {
+			t.Fatalf("expected %v, got %v", nil, err)
+		}
+	}
+
+	// the KEY-1 is evicted by the storage
+	keys, err := indexer.KeysByTag(ctx, "product")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(keys) != 1 || keys[0] != "KEY-2" {
+		t.Fatalf("expected %v, got %v", []string{"KEY-2"}, keys)
+	}
+}
+
+func TestCacheInMemoryTagsOverwritten(t *testing.T) {
+	c := gotcha.New(
+		gotcha.NewOption().SetAlgorithm(inmemcache.LRUAlgorithm).
+			SetExpiryTime(time.Minute).SetMaxSizeItem(10),
+	)
+	cacheObj := inmem.NewCache(c)
+	indexer := cacheObj.(cache.TagIndexer)
+	ctx := context.Background()
+	if err := cacheObj.Set("KEY-1", cache.CachedResponse{CachedTime: time.Now()}); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if err := indexer.AddTags(ctx, "KEY-1", []string{"product"}); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+
+	// the new response of the key isn't tagged
+	if err := cacheObj.Set("KEY-1", cache.CachedResponse{CachedTime: time.Now()}); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	keys, err := indexer.KeysByTag(ctx, "product")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected %v, got %v", []string{}, keys)
+	}
+}
@@ -0,0 +1,77 @@
+package inmem
+
+import (
+	"hash/maphash"
+)
+
+const sketchDepth = 4
+
+// frequencySketch is a count-min sketch estimating the access frequency of the keys, used by the
+// TinyLFU admission policy. The counters are halved periodically, so the old accesses fade out.
+type frequencySketch struct {
+	seed       maphash.Seed
+	counters   [sketchDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+func newFrequencySketch(items int) *frequencySketch {
+	width := 1
+	for width < items {
+		width <<= 1
+	}
+	s := &frequencySketch{
+		seed:       maphash.MakeSeed(),
+		mask:       uint64(width - 1),
+		sampleSize: 10 * width,
+	}
+	for i := range s.counters {
+		s.counters[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// indexes will return the counter index of the key in each row, using the double hashing
+func (s *frequencySketch) indexes(key string) (idx [sketchDepth]uint64) {
+	var h maphash.Hash
+	h.SetSeed(s.seed)
+	_, _ = h.WriteString(key)
+	sum := h.Sum64()
+	h1, h2 := sum, (sum>>32)|1
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) & s.mask
+	}
+	return idx
+}
+
+func (s *frequencySketch) increment(key string) {
+	for i, idx := range s.indexes(key) {
+		if s.counters[i][idx] < 255 {
+			s.counters[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+func (s *frequencySketch) estimate(key string) uint8 {
+	least := uint8(255)
+	for i, idx := range s.indexes(key) {
+		if s.counters[i][idx] < least {
+			least = s.counters[i][idx]
+		}
+	}
+	return least
+}
+
+func (s *frequencySketch) reset() {
+	for i := range s.counters {
+		for j := range s.counters[i] {
+			s.counters[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
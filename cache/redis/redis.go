@@ -4,12 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bxcodec/httpcache/cache"
 	"github.com/go-redis/redis/v8"
 )
 
+// The prefixes of the internal keys, the keys of the stored responses never start with them
+const (
+	internalKeyPrefix = "httpcache:"
+	// tagKeyPrefix is the prefix of the redis sets used for indexing the keys by tag
+	tagKeyPrefix = internalKeyPrefix + "tag:"
+	// metaKeyPrefix is the prefix of the redis hashes holding the metadata of the stored responses,
+	// so the items can be iterated without loading the responses.
+	metaKeyPrefix = internalKeyPrefix + "meta:"
+	// keyTagsPrefix is the prefix of the redis sets holding the tags of the stored responses,
+	// so the key is removed from the tag sets when it's deleted
+	keyTagsPrefix = internalKeyPrefix + "keytags:"
+
+	scanCount = 100
+)
+
 // CacheOptions for storing data for Redis connections
 type CacheOptions struct {
 	Addr     string
@@ -34,8 +51,24 @@ func NewCache(ctx context.Context, c *redis.Client, exptime time.Duration) cache
 
 func (i *redisCache) Set(key string, value cache.CachedResponse) (err error) { //nolint
 	valueJSON, _ := json.Marshal(value)
-	set := i.cache.Set(i.ctx, key, string(valueJSON), i.expiryTime*time.Second)
-	if err := set.Err(); err != nil {
+	// the tags of the overwritten response are never the tags of the new one, they're added by AddTags
+	oldTags, err := i.cache.SMembers(i.ctx, keyTagsPrefix+key).Result()
+	if err != nil {
+		return cache.ErrStorageInternal
+	}
+	pipe := i.cache.TxPipeline()
+	for _, tag := range oldTags {
+		pipe.SRem(i.ctx, tagKeyPrefix+tag, key)
+	}
+	pipe.Del(i.ctx, keyTagsPrefix+key)
+	pipe.Set(i.ctx, key, string(valueJSON), i.expiryTime)
+	pipe.HMSet(i.ctx, metaKeyPrefix+key,
+		"size", len(value.DumpedResponse),
+		"cachedTime", value.CachedTime.UnixNano())
+	if i.expiryTime > 0 {
+		pipe.Expire(i.ctx, metaKeyPrefix+key, i.expiryTime)
+	}
+	if _, err := pipe.Exec(i.ctx); err != nil {
 		fmt.Println(err)
 		return cache.ErrStorageInternal
 	}
@@ -48,20 +81,33 @@ func (i *redisCache) Get(key string) (res cache.CachedResponse, err error) {
 		if err == redis.Nil {
 			return cache.CachedResponse{}, cache.ErrCacheMissed
 		}
+		if strings.HasPrefix(err.Error(), "WRONGTYPE") {
+			// not a stored response, e.g the key of another application sharing the DB
+			return cache.CachedResponse{}, cache.ErrInvalidCachedResponse
+		}
 		return cache.CachedResponse{}, cache.ErrStorageInternal
 	}
-	val := get.Val().(string)
-	err = json.Unmarshal([]byte(val), &res)
-	if err != nil {
-		return cache.CachedResponse{}, cache.ErrStorageInternal
+	val, ok := get.Val().(string)
+	if !ok {
+		return cache.CachedResponse{}, cache.ErrInvalidCachedResponse
+	}
+	if err = json.Unmarshal([]byte(val), &res); err != nil {
+		return cache.CachedResponse{}, cache.ErrInvalidCachedResponse
 	}
 	return
 }
 
 func (i *redisCache) Delete(key string) (err error) {
-	// deleting in redis equal to setting expiration time for key to 0
-	set := i.cache.Set(i.ctx, key, nil, 0)
-	if err := set.Err(); err != nil {
+	tags, err := i.cache.SMembers(i.ctx, keyTagsPrefix+key).Result()
+	if err != nil {
+		return cache.ErrStorageInternal
+	}
+	pipe := i.cache.TxPipeline()
+	for _, tag := range tags {
+		pipe.SRem(i.ctx, tagKeyPrefix+tag, key)
+	}
+	pipe.Del(i.ctx, key, metaKeyPrefix+key, keyTagsPrefix+key)
+	if _, err := pipe.Exec(i.ctx); err != nil {
 		return cache.ErrStorageInternal
 	}
 	return nil
@@ -71,6 +117,13 @@ func (i *redisCache) Origin() string {
 	return cache.CacheRedis
 }
 
+func (i *redisCache) Ping(ctx context.Context) error {
+	if err := i.cache.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("%w: %v", cache.ErrStorageInternal, err)
+	}
+	return nil
+}
+
 func (i *redisCache) Flush() error {
 	flush := i.cache.FlushAll(i.ctx)
 	if err := flush.Err(); err != nil {
@@ -78,3 +131,129 @@ func (i *redisCache) Flush() error {
 	}
 	return nil
 }
+
+func (i *redisCache) Keys(ctx context.Context, prefix string) (keys []string, err error) {
+	err = i.scan(ctx, prefix, func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys, err
+}
+
+func (i *redisCache) Range(ctx context.Context, prefix string, fn func(info cache.ItemInfo) bool) error {
+	return i.scan(ctx, prefix, func(key string) bool {
+		pipe := i.cache.Pipeline()
+		meta := pipe.HMGet(ctx, metaKeyPrefix+key, "size", "cachedTime")
+		ttl := pipe.PTTL(ctx, key)
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			// skip the item, it might be removed in the meantime
+			return true
+		}
+
+		info := cache.ItemInfo{Key: key}
+		values := meta.Val()
+		if len(values) == 2 {
+			if size, ok := values[0].(string); ok {
+				info.Size, _ = strconv.Atoi(size)
+			}
+			if cachedTime, ok := values[1].(string); ok {
+				nsec, _ := strconv.ParseInt(cachedTime, 10, 64)
+				info.CachedTime = time.Unix(0, nsec)
+			}
+		}
+		if ttl.Val() > 0 {
+			info.ExpiresAt = time.Now().Add(ttl.Val())
+		}
+		return fn(info)
+	})
+}
+
+// scan will iterate the keys of the stored responses started with the prefix using the SCAN command.
+// The metadata keys are scanned, so only the keys stored by this cache are found in a shared DB.
+func (i *redisCache) scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	iter := i.cache.Scan(ctx, 0, escapePattern(metaKeyPrefix+prefix)+"*", scanCount).Iterator()
+	for iter.Next(ctx) {
+		key := strings.TrimPrefix(iter.Val(), metaKeyPrefix)
+		if !fn(key) {
+			return nil
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return cache.ErrStorageInternal
+	}
+	return nil
+}
+
+// escapePattern will escape the glob-style characters of the SCAN pattern
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (i *redisCache) AddTags(ctx context.Context, key string, tags []string) error {
+	pipe := i.cache.TxPipeline()
+	for _, tag := range tags {
+		pipe.SAdd(ctx, tagKeyPrefix+tag, key)
+		pipe.SAdd(ctx, keyTagsPrefix+key, tag)
+		if i.expiryTime > 0 {
+			// the index lives as long as the latest item of the tag
+			pipe.Expire(ctx, tagKeyPrefix+tag, i.expiryTime)
+		}
+	}
+	if i.expiryTime > 0 {
+		pipe.Expire(ctx, keyTagsPrefix+key, i.expiryTime)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return cache.ErrStorageInternal
+	}
+	return nil
+}
+
+// KeysByTag will return the stored keys associated to the tag, the expired or evicted keys are
+// removed from the tag set, and the set is removed by redis once it's empty
+func (i *redisCache) KeysByTag(ctx context.Context, tag string) ([]string, error) {
+	keys, err := i.cache.SMembers(ctx, tagKeyPrefix+tag).Result()
+	if err != nil {
+		return nil, cache.ErrStorageInternal
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pipe := i.cache.Pipeline()
+	exists := make([]*redis.IntCmd, len(keys))
+	for idx, key := range keys {
+		exists[idx] = pipe.Exists(ctx, key)
+	}
+	if _, err = pipe.Exec(ctx); err != nil {
+		return nil, cache.ErrStorageInternal
+	}
+	stored := keys[:0]
+	var gone []interface{}
+	for idx, key := range keys {
+		if exists[idx].Val() == 0 {
+			gone = append(gone, key)
+			continue
+		}
+		stored = append(stored, key)
+	}
+	if len(gone) > 0 {
+		if err = i.cache.SRem(ctx, tagKeyPrefix+tag, gone...).Err(); err != nil {
+			return nil, cache.ErrStorageInternal
+		}
+	}
+	return stored, nil
+}
+
+func (i *redisCache) RemoveTag(ctx context.Context, tag string) error {
+	if err := i.cache.Del(ctx, tagKeyPrefix+tag).Err(); err != nil {
+		return cache.ErrStorageInternal
+	}
+	return nil
+}
@@ -23,7 +23,7 @@ func TestCacheRedis(t *testing.T) {
 		DB:       0,  // use default DB
 	})
 
-	cacheObj := rediscache.NewCache(context.Background(), c, 15)
+	cacheObj := rediscache.NewCache(context.Background(), c, 15*time.Second)
 	testKey := "KEY"
 	testVal := cache.CachedResponse{
 		DumpedResponse: nil,
@@ -64,3 +64,189 @@ func TestCacheRedis(t *testing.T) {
 		t.Fatalf("expected %v, got %v", err, nil)
 	}
 }
+
+func TestCacheRedisTags(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+	c := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	ctx := context.Background()
+	cacheObj := rediscache.NewCache(ctx, c, 0)
+	indexer := cacheObj.(cache.TagIndexer)
+	for _, key := range []string{"KEY-1", "KEY-2", "KEY-3"} {
+		if err = cacheObj.Set(key, cache.CachedResponse{CachedTime: time.Now()}); err != nil {
+			t.Fatalf("expected %v, got %v", nil, err)
+		}
+	}
+	err = indexer.AddTags(ctx, "KEY-1", []string{"product:1", "product"})
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	err = indexer.AddTags(ctx, "KEY-2", []string{"product"})
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+
+	keys, err := indexer.KeysByTag(ctx, "product")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected %v, got %v", 2, len(keys))
+	}
+
+	// the deleted key is removed from its tag sets, and the empty set is removed
+	if err = cacheObj.Delete("KEY-1"); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if s.Exists("httpcache:tag:product:1") || s.Exists("httpcache:keytags:KEY-1") {
+		t.Fatalf("expected the tag sets of the deleted key to be removed")
+	}
+
+	// the overwritten key is removed from the tag sets of the previous response
+	err = indexer.AddTags(ctx, "KEY-3", []string{"category"})
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if err = cacheObj.Set("KEY-3", cache.CachedResponse{CachedTime: time.Now()}); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	keys, err = indexer.KeysByTag(ctx, "category")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(keys) != 0 || s.Exists("httpcache:keytags:KEY-3") {
+		t.Fatalf("expected %v, got %v", []string{}, keys)
+	}
+
+	// the key removed without Delete, e.g expired, is pruned
+	err = indexer.AddTags(ctx, "KEY-3", []string{"product"})
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	s.Del("KEY-3")
+	keys, err = indexer.KeysByTag(ctx, "product")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(keys) != 1 || keys[0] != "KEY-2" {
+		t.Fatalf("expected %v, got %v", []string{"KEY-2"}, keys)
+	}
+
+	err = indexer.RemoveTag(ctx, "product")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	keys, err = indexer.KeysByTag(ctx, "product")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected %v, got %v", 0, len(keys))
+	}
+}
+
+func TestCacheRedisRange(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+	c := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	ctx := context.Background()
+	cacheObj := rediscache.NewCache(ctx, c, 15*time.Second)
+	cachedTime := time.Now()
+	for _, key := range []string{"GET http://a/1?q=*", "GET http://a/2", "GET http://b/1"} {
+		err = cacheObj.Set(key, cache.CachedResponse{
+			DumpedResponse: []byte(key),
+			RequestURI:     key,
+			RequestMethod:  "GET",
+			CachedTime:     cachedTime,
+		})
+		if err != nil {
+			t.Fatalf("expected %v, got %v", nil, err)
+		}
+	}
+
+	var infos []cache.ItemInfo
+	err = cacheObj.(cache.Iterable).Range(ctx, "GET http://a/", func(info cache.ItemInfo) bool {
+		infos = append(infos, info)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("expected %v, got %v", 2, len(infos))
+	}
+	for _, info := range infos {
+		if info.Size != len(info.Key) {
+			t.Fatalf("expected %v, got %v", len(info.Key), info.Size)
+		}
+		if !info.CachedTime.Equal(cachedTime) {
+			t.Fatalf("expected %v, got %v", cachedTime, info.CachedTime)
+		}
+		if info.ExpiresAt.IsZero() {
+			t.Fatalf("expected the expiry time, got zero")
+		}
+	}
+
+	// the glob characters in the prefix are matched literally
+	keys, err := cacheObj.(cache.Iterable).Keys(ctx, "GET http://a/1?q=*")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected %v, got %v", 1, len(keys))
+	}
+	keys, err = cacheObj.(cache.Iterable).Keys(ctx, "GET http://a/?")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected %v, got %v", 0, len(keys))
+	}
+}
+
+func TestCacheRedisForeignKeys(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+	c := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	ctx := context.Background()
+	cacheObj := rediscache.NewCache(ctx, c, 0)
+	if err = cacheObj.Set("GET http://a/1", cache.CachedResponse{CachedTime: time.Now()}); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	// the keys of another application sharing the DB
+	if err = s.Set("session:1", "not a response"); err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	s.HSet("user:1", "name", "alice")
+
+	keys, err := cacheObj.(cache.KeyLister).Keys(ctx, "")
+	if err != nil {
+		t.Fatalf("expected %v, got %v", nil, err)
+	}
+	if len(keys) != 1 || keys[0] != "GET http://a/1" {
+		t.Fatalf("expected %v, got %v", []string{"GET http://a/1"}, keys)
+	}
+	for _, key := range []string{"session:1", "user:1"} {
+		if _, err = cacheObj.Get(key); err != cache.ErrInvalidCachedResponse {
+			t.Fatalf("expected %v, got %v", cache.ErrInvalidCachedResponse, err)
+		}
+	}
+}
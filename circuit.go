@@ -0,0 +1,231 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default circuit breaker options, see CircuitBreakerOptions
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitOpenDuration     = 30 * time.Second
+)
+
+// ErrCircuitOpen will throw if the request can't be served from the cache storage while the circuit
+// breaker of the upstream host is open, see WithCircuitBreaker
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker of an upstream host
+type CircuitState int
+
+// Circuit states
+const (
+	// CircuitClosed means the requests are sent to the upstream
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means the requests are never sent to the upstream, they fail fast
+	CircuitOpen
+	// CircuitHalfOpen means a few probe requests are sent to the upstream, to check whether it's recovered
+	CircuitHalfOpen
+)
+
+// String will return the string version of the circuit state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerOptions is the configuration of the circuit breaker, see WithCircuitBreaker
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit,
+	// the DefaultCircuitFailureThreshold if zero
+	FailureThreshold int `yaml:"failure_threshold"`
+	// OpenDuration is how long the circuit stays open before it's half-open,
+	// the DefaultCircuitOpenDuration if zero
+	OpenDuration time.Duration `yaml:"open_duration"`
+	// HalfOpenProbes is the number of the probe requests sent at a time while the circuit is half-open,
+	// 1 if zero. The circuit is closed after a successful probe, and opened again after a failed one.
+	HalfOpenProbes int `yaml:"half_open_probes"`
+	// IsFailure will check whether the upstream failed, by default the errors and the 5xx responses.
+	// The canceled requests are never failures.
+	IsFailure func(resp *http.Response, err error) bool `yaml:"-"`
+}
+
+// WithCircuitBreaker will add a circuit breaker per upstream host. The circuit opens after consecutive
+// failures of the upstream: the stale responses are served with the Warning 111 (Revalidation Failed),
+// and the requests without a stored response fail fast with the ErrCircuitOpen.
+func WithCircuitBreaker(opts CircuitBreakerOptions) Option {
+	if opts.FailureThreshold == 0 {
+		opts.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if opts.OpenDuration == 0 {
+		opts.OpenDuration = DefaultCircuitOpenDuration
+	}
+	if opts.HalfOpenProbes == 0 {
+		opts.HalfOpenProbes = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = isUpstreamFailure
+	}
+	return func(h *CacheHandler) {
+		h.breaker = &circuitBreaker{opts: opts, hosts: map[string]*circuit{}}
+	}
+}
+
+type circuitBreaker struct {
+	opts      CircuitBreakerOptions
+	mu        sync.Mutex
+	hosts     map[string]*circuit
+	halfOpens int // The number of the half-open periods of all the hosts, it identifies the probes
+}
+
+// circuit is the circuit breaker of a single host
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int // The probe requests in flight while the circuit is half-open
+	halfOpen int // The half-open period of the circuit, the outcomes of the probes of another one are ignored
+}
+
+func (b *circuitBreaker) validate() error {
+	if b.opts.FailureThreshold < 0 || b.opts.OpenDuration < 0 || b.opts.HalfOpenProbes < 0 {
+		return fmt.Errorf("%w: the circuit breaker options must not be negative", ErrInvalidOption)
+	}
+	return nil
+}
+
+// state will return the state of the host circuit, the open circuit is half-open after the open duration
+func (b *circuitBreaker) state(host string, now time.Time) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.hosts[host]
+	if !ok {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= b.opts.OpenDuration {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// allow will check whether the request can be sent to the host, the probe identifies the half-open
+// period of the probe request, it's zero if the request is not a probe
+func (b *circuitBreaker) allow(host string, now time.Time) (ok bool, probe int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.hosts[host]
+	if !ok {
+		return true, 0
+	}
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= b.opts.OpenDuration {
+		b.halfOpens++
+		c.state, c.probes, c.halfOpen = CircuitHalfOpen, 0, b.halfOpens
+	}
+	switch c.state {
+	case CircuitOpen:
+		return false, 0
+	case CircuitHalfOpen:
+		if c.probes >= b.opts.HalfOpenProbes {
+			return false, 0
+		}
+		c.probes++
+		return true, c.halfOpen
+	}
+	return true, 0
+}
+
+// record will record the outcome of the request sent to the host. While the circuit is half-open, only
+// the outcomes of its probes count, e.g not the requests sent while it was closed.
+func (b *circuitBreaker) record(host string, probe int, failure bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.hosts[host]
+	if !ok {
+		if !failure {
+			return
+		}
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+	switch c.state {
+	case CircuitClosed:
+		if !failure {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= b.opts.FailureThreshold {
+			c.state, c.openedAt = CircuitOpen, now
+			log.Printf("The circuit breaker of %s is open after %d failures\n", host, c.failures)
+		}
+	case CircuitHalfOpen:
+		if probe != c.halfOpen {
+			return
+		}
+		c.probes--
+		if failure {
+			c.state, c.openedAt = CircuitOpen, now
+			return
+		}
+		log.Printf("The circuit breaker of %s is closed\n", host)
+		delete(b.hosts, host)
+	}
+}
+
+// release will release the probe of the canceled request, its outcome is unknown
+func (b *circuitBreaker) release(host string, probe int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.hosts[host]; ok && c.state == CircuitHalfOpen && probe == c.halfOpen {
+		c.probes--
+	}
+}
+
+// CircuitState will return the state of the circuit breaker of the upstream host, it's always
+// closed without the circuit breaker
+func (r *CacheHandler) CircuitState(host string) CircuitState {
+	if r.breaker == nil {
+		return CircuitClosed
+	}
+	return r.breaker.state(host, time.Now())
+}
+
+// circuitClosed will check whether the circuit breaker of the request host is closed
+func (r *CacheHandler) circuitClosed(req *http.Request) bool {
+	return r.CircuitState(req.URL.Host) == CircuitClosed
+}
+
+// sendUpstream will send the request to the upstream, unless the circuit breaker of its host is open
+func (r *CacheHandler) sendUpstream(req *http.Request) (*http.Response, error) {
+	if r.breaker == nil {
+		return r.DefaultRoundTripper.RoundTrip(req)
+	}
+	host := req.URL.Host
+	ok, probe := r.breaker.allow(host, time.Now())
+	if !ok {
+		// the request is never sent, its body is closed like the http.RoundTripper does
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+	}
+	resp, err := r.DefaultRoundTripper.RoundTrip(req)
+	if errors.Is(err, context.Canceled) || req.Context().Err() != nil {
+		r.breaker.release(host, probe)
+		return resp, err
+	}
+	r.breaker.record(host, probe, r.breaker.opts.IsFailure(resp, err), time.Now())
+	return resp, err
+}
@@ -0,0 +1,195 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var hits, failing int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		if atomic.LoadInt64(&failing) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		// stale right away
+		w.Header().Set("Cache-Control", "max-age=0")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	host := strings.TrimPrefix(upstream.URL, "http://")
+
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithCircuitBreaker(httpcache.CircuitBreakerOptions{
+			FailureThreshold: 2,
+			OpenDuration:     100 * time.Millisecond,
+		}))
+	require.NoError(t, err)
+	get := func(path string) (*http.Response, string, error) {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, upstream.URL+path, http.NoBody)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body), nil
+	}
+
+	_, _, err = get("/stale")
+	require.NoError(t, err)
+	atomic.StoreInt64(&failing, 1)
+	for i := 0; i < 2; i++ {
+		resp, _, err := get("/missing")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	}
+	require.Equal(t, httpcache.CircuitOpen, handler.CircuitState(host))
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+	// the upstream is not contacted while the circuit is open
+	resp, body, err := get("/stale")
+	require.NoError(t, err)
+	require.Equal(t, "/stale", body)
+	require.True(t, strings.HasPrefix(resp.Header.Get(httpcache.HeaderWarning), "111 "))
+	_, _, err = get("/missing")
+	require.True(t, errors.Is(err, httpcache.ErrCircuitOpen), err)
+	var urlErr *url.Error
+	require.True(t, errors.As(err, &urlErr))
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+	// the failed probe opens the circuit again
+	time.Sleep(150 * time.Millisecond)
+	require.Equal(t, httpcache.CircuitHalfOpen, handler.CircuitState(host))
+	resp, _, err = get("/missing")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	require.Equal(t, httpcache.CircuitOpen, handler.CircuitState(host))
+
+	// the successful probe closes the circuit
+	time.Sleep(150 * time.Millisecond)
+	atomic.StoreInt64(&failing, 0)
+	_, body, err = get("/missing")
+	require.NoError(t, err)
+	require.Equal(t, "/missing", body)
+	require.Equal(t, httpcache.CircuitClosed, handler.CircuitState(host))
+	require.EqualValues(t, 5, atomic.LoadInt64(&hits))
+}
+
+func TestCircuitBreakerInvalidOptions(t *testing.T) {
+	_, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithCircuitBreaker(httpcache.CircuitBreakerOptions{FailureThreshold: -1}))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+}
+
+func TestCircuitBreakerStraggler(t *testing.T) {
+	entered := make(chan string, 2)
+	release := map[string]chan struct{}{"/straggler": make(chan struct{}), "/probe": make(chan struct{})}
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if wait, ok := release[r.URL.Path]; ok {
+			entered <- r.URL.Path
+			<-wait
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	released := map[string]bool{}
+	releasePath := func(path string) {
+		if !released[path] {
+			released[path] = true
+			close(release[path])
+		}
+	}
+	t.Cleanup(func() {
+		// the blocked requests never keep the upstream from closing, e.g the test failed
+		releasePath("/straggler")
+		releasePath("/probe")
+	})
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithCircuitBreaker(httpcache.CircuitBreakerOptions{
+			FailureThreshold: 1,
+			OpenDuration:     50 * time.Millisecond,
+		}))
+	require.NoError(t, err)
+	getAsync := func(path string) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			doGet(t, client, upstream.URL+path)
+		}()
+		require.Equal(t, path, <-entered)
+		return done
+	}
+
+	// the straggler is sent while the circuit is closed, the probe while it's half-open
+	straggler := getAsync("/straggler")
+	doGet(t, client, upstream.URL+"/fail")
+	require.Equal(t, httpcache.CircuitOpen, handler.CircuitState(host))
+	time.Sleep(60 * time.Millisecond)
+	probe := getAsync("/probe")
+
+	// the outcome of the straggler doesn't count
+	releasePath("/straggler")
+	<-straggler
+	require.Equal(t, httpcache.CircuitHalfOpen, handler.CircuitState(host))
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, upstream.URL+"/other", http.NoBody)
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	require.True(t, errors.Is(err, httpcache.ErrCircuitOpen), err)
+
+	releasePath("/probe")
+	<-probe
+	require.Equal(t, httpcache.CircuitClosed, handler.CircuitState(host))
+}
+
+// closeTracker tracks whether the request body is closed
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeTracker) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestCircuitBreakerOpenClosesBody(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	handler, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithCircuitBreaker(httpcache.CircuitBreakerOptions{FailureThreshold: 1, OpenDuration: time.Minute}))
+	require.NoError(t, err)
+	post := func() (*closeTracker, error) {
+		body := &closeTracker{Reader: strings.NewReader("{}")}
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, upstream.URL, body)
+		require.NoError(t, err)
+		resp, err := handler.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return body, err
+	}
+
+	_, err = post()
+	require.NoError(t, err)
+	body, err := post()
+	require.True(t, errors.Is(err, httpcache.ErrCircuitOpen), err)
+	require.True(t, body.closed)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/admin"
+	"github.com/bxcodec/httpcache/cache/inmem"
+)
+
+var errNoUpstream = errors.New("no upstream matched")
+
+// adminHandler will return the handler of the admin endpoints, the metrics in prometheus text format
+// are served on /metrics, the rest is served by the admin.Handler. The url parameter of the admin
+// endpoints is the one requested to the proxy.
+func (p *proxy) adminHandler() http.Handler {
+	api := admin.NewHandler(p.handler)
+	api.RewriteRequest = func(req *http.Request) error {
+		req.Host = req.URL.Host
+		if p.match(req) == nil {
+			return errNoUpstream
+		}
+		p.direct(req)
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", p.serveMetrics)
+	mux.Handle("/", api)
+	return mux
+}
+
+func (p *proxy) serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP httpcache_events_total The number of cache events per type.")
+	fmt.Fprintln(w, "# TYPE httpcache_events_total counter")
+	for _, e := range httpcache.EventTypes() {
+		fmt.Fprintf(w, "httpcache_events_total{type=%q} %d\n", e.String(), p.stats.Count(e))
+	}
+	healthy := 0
+	if p.handler.StorageHealthy() {
+		healthy = 1
+	}
+	fmt.Fprintln(w, "# HELP httpcache_storage_healthy Whether the cache storage is healthy, it's bypassed if not.")
+	fmt.Fprintln(w, "# TYPE httpcache_storage_healthy gauge")
+	fmt.Fprintf(w, "httpcache_storage_healthy %d\n", healthy)
+
+	if bounded, ok := p.handler.CacheInteractor.(*inmem.BoundedCache); ok {
+		stats := bounded.Stats()
+		fmt.Fprintln(w, "# HELP httpcache_storage_bytes The total size of the stored items.")
+		fmt.Fprintln(w, "# TYPE httpcache_storage_bytes gauge")
+		fmt.Fprintf(w, "httpcache_storage_bytes %d\n", stats.Size)
+		fmt.Fprintln(w, "# HELP httpcache_storage_items The number of the stored items.")
+		fmt.Fprintln(w, "# TYPE httpcache_storage_items gauge")
+		fmt.Fprintf(w, "httpcache_storage_items %d\n", stats.Items)
+		fmt.Fprintln(w, "# HELP httpcache_storage_evictions_total The number of items evicted to free up space.")
+		fmt.Fprintln(w, "# TYPE httpcache_storage_evictions_total counter")
+		fmt.Fprintf(w, "httpcache_storage_evictions_total %d\n", stats.Evictions)
+		fmt.Fprintln(w, "# HELP httpcache_storage_rejections_total The number of items refused by the storage.")
+		fmt.Fprintln(w, "# TYPE httpcache_storage_rejections_total counter")
+		fmt.Fprintf(w, "httpcache_storage_rejections_total %d\n", stats.Rejections)
+	}
+}
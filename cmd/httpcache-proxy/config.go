@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"gopkg.in/yaml.v2"
+)
+
+// Storage types
+const (
+	StorageInmem = "inmem"
+	StorageRedis = "redis"
+	StorageDisk  = "disk"
+)
+
+// Config is the configuration of the caching proxy, loaded from a YAML (or JSON) file
+type Config struct {
+	Listen      string           `yaml:"listen"`       // The address the proxy listen to, e.g ":8080"
+	AdminListen string           `yaml:"admin_listen"` // The address of the admin endpoints, e.g "127.0.0.1:9090"
+	Upstreams   []UpstreamConfig `yaml:"upstreams"`
+	Storage     StorageConfig    `yaml:"storage"`
+	// The per-route cache policies, see httpcache.ParseRules. The rules match the request
+	// forwarded to the upstream, i.e the host of a rule is the upstream host.
+	Rules []httpcache.Rule `yaml:"rules"`
+	// The TTL of the error responses per status code, see httpcache.WithNegativeCaching
+	NegativeTTLs map[int]time.Duration `yaml:"negative_ttls"`
+	// The circuit breaker per upstream host, disabled if not set, see httpcache.WithCircuitBreaker
+	CircuitBreaker *httpcache.CircuitBreakerOptions `yaml:"circuit_breaker"`
+	// The write-behind store, the responses are stored synchronously if not set, see httpcache.WithAsyncStore
+	AsyncStore *httpcache.AsyncStoreOptions `yaml:"async_store"`
+	// The storage health tracking, the storage is never bypassed if not set, see httpcache.WithStorageHealth
+	StorageHealth *httpcache.StorageHealthOptions `yaml:"storage_health"`
+}
+
+// UpstreamConfig represent a single upstream. A request is forwarded to the first upstream
+// that match the request's host and path prefix.
+type UpstreamConfig struct {
+	Host         string `yaml:"host"`          // Optional, match the request Host header
+	PathPrefix   string `yaml:"path_prefix"`   // Optional, match the request path prefix
+	URL          string `yaml:"url"`           // The upstream URL, e.g "http://10.0.0.1:8080"
+	PreserveHost bool   `yaml:"preserve_host"` // Send the original Host header to the upstream
+
+	target *url.URL
+}
+
+// StorageConfig is the configuration of the cache storage backend
+type StorageConfig struct {
+	Type  string        `yaml:"type"` // One of inmem, redis, disk
+	TTL   time.Duration `yaml:"ttl"`  // The max lifetime of an item in the storage
+	Dir   string        `yaml:"dir"`  // Only for disk
+	Redis RedisConfig   `yaml:"redis"`
+
+	// Only for inmem. If the max_bytes is set, the storage is bounded by the total size
+	// of the stored items, otherwise by the max_items.
+	MaxItems     uint64 `yaml:"max_items"`
+	MaxBytes     int64  `yaml:"max_bytes"`
+	MaxItemBytes int64  `yaml:"max_item_bytes"`
+	Policy       string `yaml:"policy"` // lru (default) or tinylfu
+}
+
+// RedisConfig is the configuration of the redis storage
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+// LoadConfig will read and validate the configuration file
+func LoadConfig(path string) (cfg Config, err error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return
+	}
+	return ParseConfig(raw)
+}
+
+// ParseConfig will parse and validate the configuration
+func ParseConfig(raw []byte) (cfg Config, err error) {
+	cfg = Config{
+		Listen:      ":8080",
+		AdminListen: "127.0.0.1:9090",
+		Storage: StorageConfig{
+			Type:     StorageInmem,
+			MaxItems: 100,
+		},
+	}
+	if err = yaml.UnmarshalStrict(raw, &cfg); err != nil {
+		return Config{}, err
+	}
+	if err = cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Upstreams) == 0 {
+		return errors.New("at least one upstream is required")
+	}
+	for i := range c.Upstreams {
+		target, err := url.Parse(c.Upstreams[i].URL)
+		if err != nil {
+			return fmt.Errorf("invalid url of upstream %d: %w", i, err)
+		}
+		if target.Scheme == "" || target.Host == "" {
+			return fmt.Errorf("invalid url of upstream %d: scheme and host are required", i)
+		}
+		c.Upstreams[i].target = target
+	}
+
+	switch c.Storage.Type {
+	case StorageInmem:
+		switch c.Storage.Policy {
+		case "", inmem.PolicyLRU, inmem.PolicyTinyLFU:
+		default:
+			return fmt.Errorf("unknown storage policy %q", c.Storage.Policy)
+		}
+	case StorageRedis:
+		if c.Storage.Redis.Addr == "" {
+			return errors.New("storage.redis.addr is required for redis storage")
+		}
+	case StorageDisk:
+		if c.Storage.Dir == "" {
+			return errors.New("storage.dir is required for disk storage")
+		}
+	default:
+		return fmt.Errorf("unknown storage type %q", c.Storage.Type)
+	}
+	return nil
+}
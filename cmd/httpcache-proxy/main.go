@@ -0,0 +1,204 @@
+// Command httpcache-proxy is a standalone caching reverse proxy built on top of the httpcache.CacheHandler.
+//
+// It behaves as a shared cache (RFC 7234), so the s-maxage directive is honored and responses
+// marked as private are never stored. The admin endpoints (metrics, purge and flush) are served
+// on a separate address.
+//
+// Usage:
+//
+//	httpcache-proxy -config proxy.yaml
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bxcodec/gotcha"
+	inmemcache "github.com/bxcodec/gotcha/cache"
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache"
+	"github.com/bxcodec/httpcache/cache/disk"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	rediscache "github.com/bxcodec/httpcache/cache/redis"
+	"github.com/go-redis/redis/v8"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	configPath := flag.String("config", "httpcache-proxy.yaml", "path of the configuration file")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("failed to load the configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the storage outlives the signal, the queued responses are stored while shutting down
+	storageCtx, cancelStorage := context.WithCancel(context.Background())
+	defer cancelStorage()
+	p, err := newProxy(cfg, newStorage(storageCtx, cfg.Storage), http.DefaultTransport)
+	if err != nil {
+		log.Fatalf("failed to create the proxy: %v", err)
+	}
+	servers := []*http.Server{
+		{Addr: cfg.Listen, Handler: p, ReadHeaderTimeout: shutdownTimeout},
+		{Addr: cfg.AdminListen, Handler: p.adminHandler(), ReadHeaderTimeout: shutdownTimeout},
+	}
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			log.Printf("listening on %s", srv.Addr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("failed to serve on %s: %v", srv.Addr, err)
+			}
+		}(srv)
+	}
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to shutdown %s: %v", srv.Addr, err)
+		}
+	}
+	if err := p.handler.Close(shutdownCtx); err != nil {
+		log.Printf("failed to store the queued responses: %v", err)
+	}
+	cancelStorage()
+}
+
+func newStorage(ctx context.Context, cfg StorageConfig) cache.ICacheInteractor {
+	switch cfg.Type {
+	case StorageRedis:
+		c := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		return rediscache.NewCache(ctx, c, cfg.TTL)
+	case StorageDisk:
+		return disk.NewCache(cfg.Dir, cfg.TTL)
+	default:
+		if cfg.MaxBytes > 0 {
+			return inmem.NewBoundedCache(inmem.BoundedCacheOptions{
+				MaxBytes:     cfg.MaxBytes,
+				MaxItemBytes: cfg.MaxItemBytes,
+				ExpiryTime:   cfg.TTL,
+				Policy:       cfg.Policy,
+			})
+		}
+		c := gotcha.New(
+			gotcha.NewOption().SetAlgorithm(inmemcache.LRUAlgorithm).
+				SetExpiryTime(cfg.TTL).SetMaxSizeItem(cfg.MaxItems),
+		)
+		return inmem.NewCache(c)
+	}
+}
+
+type proxy struct {
+	upstreams []UpstreamConfig
+	handler   *httpcache.CacheHandler
+	stats     *httpcache.Stats
+	reverse   *httputil.ReverseProxy
+}
+
+func newProxy(cfg Config, store cache.ICacheInteractor, transport http.RoundTripper) (p *proxy, err error) {
+	p = &proxy{
+		upstreams: cfg.Upstreams,
+		stats:     &httpcache.Stats{},
+	}
+	// The proxy is a shared cache, so it must comply to RFC 7234.
+	opts := []httpcache.Option{
+		httpcache.WithRFCCompliance(true),
+		httpcache.WithCacheMode(httpcache.SharedCache),
+		httpcache.WithTransport(transport),
+		httpcache.WithObserver(p.stats),
+		httpcache.WithRules(cfg.Rules...),
+		httpcache.WithNegativeCaching(cfg.NegativeTTLs),
+	}
+	if cfg.CircuitBreaker != nil {
+		opts = append(opts, httpcache.WithCircuitBreaker(*cfg.CircuitBreaker))
+	}
+	if cfg.AsyncStore != nil {
+		opts = append(opts, httpcache.WithAsyncStore(*cfg.AsyncStore))
+	}
+	if cfg.StorageHealth != nil {
+		opts = append(opts, httpcache.WithStorageHealth(*cfg.StorageHealth))
+	}
+	p.handler, err = httpcache.New(&http.Client{}, store, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.reverse = &httputil.ReverseProxy{
+		Director:  p.direct,
+		Transport: p.handler,
+	}
+	return p, nil
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if p.match(req) == nil {
+		http.Error(w, "no upstream matched", http.StatusBadGateway)
+		return
+	}
+	p.reverse.ServeHTTP(w, req)
+}
+
+// match will return the first upstream that match the request
+func (p *proxy) match(req *http.Request) *UpstreamConfig {
+	for i := range p.upstreams {
+		u := &p.upstreams[i]
+		if u.Host != "" && !strings.EqualFold(u.Host, req.Host) {
+			continue
+		}
+		if u.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, u.PathPrefix) {
+			continue
+		}
+		return u
+	}
+	return nil
+}
+
+// direct will rewrite the request to be sent to the matched upstream
+func (p *proxy) direct(req *http.Request) {
+	u := p.match(req)
+	if u == nil {
+		return
+	}
+	req.URL.Scheme = u.target.Scheme
+	req.URL.Host = u.target.Host
+	req.URL.Path = singleJoiningSlash(u.target.Path, req.URL.Path)
+	if u.target.RawQuery != "" && req.URL.RawQuery != "" {
+		req.URL.RawQuery = u.target.RawQuery + "&" + req.URL.RawQuery
+	} else if u.target.RawQuery != "" {
+		req.URL.RawQuery = u.target.RawQuery
+	}
+	if !u.PreserveHost {
+		req.Host = u.target.Host
+	}
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/disk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+listen: ":8000"
+upstreams:
+  - path_prefix: /api
+    url: http://10.0.0.1:8080
+storage:
+  type: disk
+  dir: /tmp/httpcache
+  ttl: 5m
+rules:
+  - path: /api/static/**
+    ttl: 1h
+negative_ttls:
+  404: 30s
+circuit_breaker:
+  failure_threshold: 3
+  open_duration: 10s
+async_store:
+  workers: 8
+storage_health:
+  backoff: 2s
+`))
+	require.NoError(t, err)
+	require.Equal(t, ":8000", cfg.Listen)
+	require.Equal(t, "127.0.0.1:9090", cfg.AdminListen)
+	require.Equal(t, StorageDisk, cfg.Storage.Type)
+	require.Equal(t, 5*time.Minute, cfg.Storage.TTL)
+	require.Equal(t, "10.0.0.1:8080", cfg.Upstreams[0].target.Host)
+	require.Len(t, cfg.Rules, 1)
+	require.Equal(t, time.Hour, cfg.Rules[0].TTL)
+	require.Equal(t, 30*time.Second, cfg.NegativeTTLs[404])
+	require.Equal(t, 3, cfg.CircuitBreaker.FailureThreshold)
+	require.Equal(t, 10*time.Second, cfg.CircuitBreaker.OpenDuration)
+	require.Equal(t, 8, cfg.AsyncStore.Workers)
+	require.Equal(t, 2*time.Second, cfg.StorageHealth.Backoff)
+
+	_, err = ParseConfig([]byte(`upstreams: []`))
+	require.Error(t, err)
+
+	_, err = ParseConfig([]byte(`
+upstreams:
+  - url: http://10.0.0.1:8080
+storage:
+  type: memcached
+`))
+	require.Error(t, err)
+}
+
+func TestProxySharedCache(t *testing.T) {
+	var hits int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		switch r.URL.Path {
+		case "/shared":
+			w.Header().Set("Cache-Control", "max-age=0, s-maxage=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		}
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	cfg, err := ParseConfig([]byte("upstreams:\n  - url: " + upstream.URL + "\n"))
+	require.NoError(t, err)
+	p, err := newProxy(cfg, disk.NewCache(t.TempDir(), time.Minute), http.DefaultTransport)
+	require.NoError(t, err)
+	front := httptest.NewServer(p)
+	defer front.Close()
+	admin := httptest.NewServer(p.adminHandler())
+	defer admin.Close()
+
+	get := func(path string) string {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, front.URL+path, http.NoBody)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return string(body)
+	}
+
+	// s-maxage is honored by the shared cache
+	require.Equal(t, "/shared", get("/shared"))
+	require.Equal(t, "/shared", get("/shared"))
+	require.EqualValues(t, 1, atomic.LoadInt64(&hits))
+	require.EqualValues(t, 1, p.stats.Count(httpcache.EventCacheHit))
+
+	// private is never stored
+	require.Equal(t, "/private", get("/private"))
+	require.Equal(t, "/private", get("/private"))
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+	// purge the shared item, the next request must go to the upstream
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost,
+		admin.URL+"/purge?url="+url.QueryEscape(front.URL+"/shared"), http.NoBody)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Equal(t, "/shared", get("/shared"))
+	require.EqualValues(t, 4, atomic.LoadInt64(&hits))
+
+	// purge by the prefix of the url requested to the proxy
+	req, err = http.NewRequestWithContext(context.TODO(), http.MethodPost,
+		admin.URL+"/purge?prefix="+url.QueryEscape(front.URL+"/sha"), http.NoBody)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.JSONEq(t, `{"purged":1}`, string(body))
+
+	require.Equal(t, "/shared", get("/shared"))
+	require.EqualValues(t, 5, atomic.LoadInt64(&hits))
+}
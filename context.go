@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// The context keys of the per-request cache control, see WithBypass, WithForceRefresh, WithTTLOverride,
+// WithCacheKey and WithTags
+type (
+	bypassKey       struct{}
+	forceRefreshKey struct{}
+	ttlOverrideKey  struct{}
+	cacheKeyKey     struct{}
+	requestTagsKey  struct{}
+)
+
+// WithBypass will make the request bypass the cache, like the Rule.Bypass: it's sent to the upstream
+// and its response is never stored
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// WithForceRefresh will make the request served by the upstream regardless of the stored response,
+// its response is stored if it's cachable
+func WithForceRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceRefreshKey{}, true)
+}
+
+// WithTTLOverride will store the response of the request for the ttl, like the Rule.TTL the response is
+// cachable even if its status isn't cachable by default. It takes precedence over the rules and the negative
+// caching. It's ignored if the ttl is not positive.
+func WithTTLOverride(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlOverrideKey{}, ttl)
+}
+
+// WithCacheKey will use the key instead of the URL to store and retrieve the response of the GET and HEAD
+// requests, it takes precedence over the KeyFunc. The responses are still partitioned, and the key is
+// ignored for the other methods.
+func WithCacheKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, cacheKeyKey{}, key)
+}
+
+// WithTags will add the tags to the stored response of the request, in addition to the tags of the
+// response headers, see PurgeTag
+func WithTags(ctx context.Context, tags ...string) context.Context {
+	existing, _ := ctx.Value(requestTagsKey{}).([]string)
+	return context.WithValue(ctx, requestTagsKey{}, append(append([]string(nil), existing...), tags...))
+}
+
+// bypass will check whether the request must bypass the cache
+func bypass(req *http.Request) bool {
+	bypass, _ := req.Context().Value(bypassKey{}).(bool)
+	return bypass
+}
+
+// forceRefresh will check whether the request must be served by the upstream
+func forceRefresh(req *http.Request) bool {
+	refresh, _ := req.Context().Value(forceRefreshKey{}).(bool)
+	return refresh
+}
+
+// ttlOverride will return the ttl overriding the freshness of the response of the request
+func ttlOverride(req *http.Request) (ttl time.Duration, ok bool) {
+	ttl, _ = req.Context().Value(ttlOverrideKey{}).(time.Duration)
+	return ttl, ttl > 0
+}
+
+// requestCacheKey will return the key set for the request
+func requestCacheKey(req *http.Request) (key string, ok bool) {
+	key, _ = req.Context().Value(cacheKeyKey{}).(string)
+	return key, key != ""
+}
+
+// requestTags will return the tags added to the stored response of the request
+func requestTags(req *http.Request) []string {
+	tags, _ := req.Context().Value(requestTagsKey{}).([]string)
+	return tags
+}
@@ -0,0 +1,151 @@
+package httpcache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextCacheControl(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		if r.URL.Path == "/accepted" {
+			// uncachable by default, without freshness
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}))
+	require.NoError(t, err)
+	get := func(ctx context.Context, path string) string {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL+path, http.NoBody)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	stored := func(ctx context.Context, path string) (httpcache.Entry, bool) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL+path, http.NoBody)
+		require.NoError(t, err)
+		entry, err := handler.Lookup(req)
+		if err != nil {
+			return entry, false
+		}
+		entry.Response.Body.Close()
+		return entry, true
+	}
+	ctx := context.TODO()
+
+	t.Run("bypass", func(t *testing.T) {
+		atomic.StoreInt64(&hits, 0)
+		get(httpcache.WithBypass(ctx), "/bypass")
+		_, ok := stored(ctx, "/bypass")
+		require.False(t, ok)
+		get(ctx, "/bypass")
+		get(httpcache.WithBypass(ctx), "/bypass")
+		require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+	})
+
+	t.Run("force refresh", func(t *testing.T) {
+		atomic.StoreInt64(&hits, 0)
+		get(ctx, "/refresh")
+		before, _ := stored(ctx, "/refresh")
+		time.Sleep(10 * time.Millisecond)
+		get(httpcache.WithForceRefresh(ctx), "/refresh")
+		after, ok := stored(ctx, "/refresh")
+		require.True(t, ok)
+		require.True(t, after.Item.CachedTime.After(before.Item.CachedTime))
+		require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+	})
+
+	t.Run("ttl override", func(t *testing.T) {
+		get(httpcache.WithTTLOverride(ctx, time.Hour), "/ttl")
+		entry, ok := stored(ctx, "/ttl")
+		require.True(t, ok)
+		require.WithinDuration(t, time.Now().Add(time.Hour), entry.ExpiresAt, time.Minute)
+	})
+
+	t.Run("ttl override uncachable by default", func(t *testing.T) {
+		get(httpcache.WithTTLOverride(ctx, time.Hour), "/accepted")
+		entry, ok := stored(ctx, "/accepted")
+		require.True(t, ok)
+		require.Equal(t, http.StatusAccepted, entry.Response.StatusCode)
+		require.WithinDuration(t, time.Now().Add(time.Hour), entry.ExpiresAt, time.Minute)
+	})
+
+	t.Run("cache key", func(t *testing.T) {
+		atomic.StoreInt64(&hits, 0)
+		keyCtx := httpcache.WithCacheKey(ctx, "custom")
+		require.Equal(t, "/first", get(keyCtx, "/first"))
+		require.Equal(t, "/first", get(keyCtx, "/second"))
+		require.EqualValues(t, 1, atomic.LoadInt64(&hits))
+		_, ok := stored(ctx, "/first")
+		require.False(t, ok)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		get(httpcache.WithTags(httpcache.WithTags(ctx, "product:1"), "product"), "/tagged")
+		get(httpcache.WithTags(ctx, "product:2"), "/other")
+		purged, err := handler.PurgeTag(ctx, "product:1")
+		require.NoError(t, err)
+		require.Equal(t, 1, purged)
+		_, ok := stored(ctx, "/tagged")
+		require.False(t, ok)
+		_, ok = stored(ctx, "/other")
+		require.True(t, ok)
+	})
+}
+
+func TestContextCacheKeyScope(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.Method+" "+r.Header.Get("X-Tenant"))
+	})
+	client := &http.Client{}
+	_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithBodyKey(httpcache.BodyKeyOptions{}),
+		httpcache.WithPartition(httpcache.PartitionByHeader("X-Tenant")))
+	require.NoError(t, err)
+	do := func(method, tenant string) string {
+		req, err := http.NewRequestWithContext(httpcache.WithCacheKey(context.TODO(), "custom"), method,
+			upstream.URL, strings.NewReader("{}"))
+		require.NoError(t, err)
+		req.Header.Set("X-Tenant", tenant)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	require.Equal(t, "GET a", do(http.MethodGet, "a"))
+	require.Equal(t, "GET a", do(http.MethodGet, "a"))
+	require.EqualValues(t, 1, atomic.LoadInt64(&hits))
+
+	// the other methods go to the upstream
+	require.Equal(t, "DELETE a", do(http.MethodDelete, "a"))
+	require.Equal(t, "POST a", do(http.MethodPost, "a"))
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+	// the partitions don't share the key
+	require.Equal(t, "GET b", do(http.MethodGet, "b"))
+	require.EqualValues(t, 4, atomic.LoadInt64(&hits))
+}
@@ -0,0 +1,43 @@
+package httpcache
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bxcodec/httpcache/cache"
+)
+
+// Entry represent an item stored in the cache storage, it's used for inspecting the cache
+type Entry struct {
+	Key       string
+	Item      cache.CachedResponse
+	Response  *http.Response // The stored response, parsed from the Item
+	ExpiresAt time.Time      // The time the stored response become stale
+}
+
+// Age will return the time elapsed since the response is stored
+func (e Entry) Age(now time.Time) time.Duration {
+	return now.Sub(e.Item.CachedTime)
+}
+
+// FreshnessLeft will return the remaining freshness lifetime of the stored response,
+// a negative value means the stored response is already stale.
+func (e Entry) FreshnessLeft(now time.Time) time.Duration {
+	return e.ExpiresAt.Sub(now)
+}
+
+// Size will return the size of the stored response in bytes
+func (e Entry) Size() int {
+	return len(e.Item.DumpedResponse)
+}
+
+// Lookup will retrieve the stored entry for the request regardless its freshness.
+// The caller is responsible to close the Response body.
+func (r *CacheHandler) Lookup(req *http.Request) (entry Entry, err error) {
+	entry.Key = r.CacheKey(req)
+	entry.Response, entry.Item, entry.ExpiresAt, err = r.lookupCachedResponse(req, entry.Key)
+	if err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,34 @@
+package httpcache
+
+import (
+	"errors"
+)
+
+// Errors returned by the constructors when the handler can't be configured
+var (
+	// ErrNilClient will throw if the HTTP client is nil
+	ErrNilClient = errors.New("http client is not set")
+	// ErrNilCacheInteractor will throw if the cache storage is nil
+	ErrNilCacheInteractor = errors.New("cache storage is not set")
+	// ErrNilTransport will throw if the round tripper is nil
+	ErrNilTransport = errors.New("round tripper is not set")
+	// ErrInvalidOption will throw if an option, or a combination of options, is invalid
+	ErrInvalidOption = errors.New("invalid option")
+	// ErrStorageUnreachable will throw if the cache storage can't be reached when pinged
+	ErrStorageUnreachable = errors.New("cache storage is unreachable")
+)
+
+// Errors carried by the EventCacheSkipped when the response is refused by the handler
+var (
+	// ErrResponseTooLarge will throw if the response is larger than the max response size
+	ErrResponseTooLarge = errors.New("response is too large to be cached")
+	// ErrRequestTooLarge will throw if the request body is larger than the max size hashed into the key
+	ErrRequestTooLarge = errors.New("request body is too large to be hashed into the key")
+	// ErrUncachableStatus will throw if the response status is never cached, e.g a partial content
+	// or a 5xx, see WithNegativeCaching
+	ErrUncachableStatus = errors.New("response status is not cachable")
+	// ErrInvalidGraphQL will throw if the GraphQL operation of the request can't be parsed, see WithGraphQL
+	ErrInvalidGraphQL = errors.New("GraphQL request can't be parsed")
+	// ErrGraphQLErrors will throw if the GraphQL response has errors, see WithGraphQL
+	ErrGraphQLErrors = errors.New("GraphQL response has errors")
+)
@@ -0,0 +1,42 @@
+package httpcache_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/bxcodec/httpcache/cache/redis"
+	"github.com/bxcodec/httpcache/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstructorErrors(t *testing.T) {
+	_, err := httpcache.New(nil, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}))
+	require.True(t, errors.Is(err, httpcache.ErrNilClient), err)
+
+	_, err = httpcache.New(&http.Client{}, nil)
+	require.True(t, errors.Is(err, httpcache.ErrNilCacheInteractor), err)
+
+	_, err = httpcache.NewCacheHandlerRoundtrip(nil, true, new(mocks.ICacheInteractor))
+	require.True(t, errors.Is(err, httpcache.ErrNilTransport), err)
+
+	_, err = httpcache.New(&http.Client{}, new(mocks.ICacheInteractor), httpcache.WithMaxResponseSize(-1))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+
+	_, err = httpcache.NewWithRedisCache(&http.Client{}, true, nil)
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+
+	// nothing is listening on the address
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	client := &http.Client{}
+	_, err = httpcache.NewWithRedisCache(client, true, &redis.CacheOptions{Addr: addr})
+	require.True(t, errors.Is(err, httpcache.ErrStorageUnreachable), err)
+	require.Nil(t, client.Transport, "the client must be left untouched")
+}
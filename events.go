@@ -0,0 +1,122 @@
+package httpcache
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+)
+
+// EventType represent the kind of Event emitted by the CacheHandler
+type EventType int
+
+// Event types
+const (
+	// EventCacheHit emitted when the response is served from the cache storage,
+	// except the error responses, see EventCacheNegativeHit
+	EventCacheHit EventType = iota
+	// EventCacheMiss emitted when the response is not found (or not fresh) in the cache storage
+	EventCacheMiss
+	// EventCacheStored emitted when the response is stored to the cache storage
+	EventCacheStored
+	// EventCacheSkipped emitted when the response is not stored because it's not cachable,
+	// or it's refused by the storage
+	EventCacheSkipped
+	// EventStorageError emitted when the cache storage failed to serve a Get or Set
+	EventStorageError
+	// EventCacheStale emitted when a stale response is served from the cache storage, while it's
+	// revalidated in the background or because the upstream failed
+	EventCacheStale
+	// EventCacheNegativeHit emitted when an error response, i.e the status code 400 and above,
+	// is served from the cache storage
+	EventCacheNegativeHit
+	// EventStorageUnhealthy emitted when the cache storage becomes unhealthy after consecutive failures,
+	// see WithStorageHealth
+	EventStorageUnhealthy
+	// EventStorageHealthy emitted when the unhealthy cache storage is healthy again, its Request is nil
+	EventStorageHealthy
+	// EventStorageBypassed emitted when the cache storage is bypassed because it's unhealthy
+	EventStorageBypassed
+
+	numEventTypes
+)
+
+// String will return the string version of the event type
+func (e EventType) String() string {
+	switch e {
+	case EventCacheHit:
+		return "hit"
+	case EventCacheMiss:
+		return "miss"
+	case EventCacheStored:
+		return "stored"
+	case EventCacheSkipped:
+		return "skipped"
+	case EventStorageError:
+		return "storage_error"
+	case EventCacheStale:
+		return "stale"
+	case EventCacheNegativeHit:
+		return "negative_hit"
+	case EventStorageUnhealthy:
+		return "storage_unhealthy"
+	case EventStorageHealthy:
+		return "storage_healthy"
+	case EventStorageBypassed:
+		return "storage_bypassed"
+	}
+	return "unknown"
+}
+
+// EventTypes return all the known event types
+func EventTypes() []EventType {
+	types := make([]EventType, 0, numEventTypes)
+	for e := EventType(0); e < numEventTypes; e++ {
+		types = append(types, e)
+	}
+	return types
+}
+
+// Event represent something that happened while the CacheHandler processing a request
+type Event struct {
+	Type    EventType
+	Key     string
+	Request *http.Request
+	Reasons []cacheControl.Reason // The reasons the response is not cachable, only for EventCacheSkipped
+	Err     error
+}
+
+// Observer is the interface contract for receiving the events from the CacheHandler.
+// OnEvent is called synchronously in the request path, so it should return quickly.
+type Observer interface {
+	OnEvent(ev Event)
+}
+
+// ObserverFunc is an adapter to allow the use of ordinary functions as Observer
+type ObserverFunc func(ev Event)
+
+// OnEvent calls f(ev)
+func (f ObserverFunc) OnEvent(ev Event) {
+	f(ev)
+}
+
+// Stats is an Observer that counts the events per type
+type Stats struct {
+	counts [numEventTypes]uint64
+}
+
+// OnEvent implements the Observer
+func (s *Stats) OnEvent(ev Event) {
+	if ev.Type < 0 || ev.Type >= numEventTypes {
+		return
+	}
+	atomic.AddUint64(&s.counts[ev.Type], 1)
+}
+
+// Count will return the number of events received for the given type
+func (s *Stats) Count(e EventType) uint64 {
+	if e < 0 || e >= numEventTypes {
+		return 0
+	}
+	return atomic.LoadUint64(&s.counts[e])
+}
@@ -10,6 +10,7 @@ require (
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/stretchr/testify v1.5.1
 	golang.org/x/net v0.17.0
+	gopkg.in/yaml.v2 v2.2.7
 )
 
 require (
@@ -25,5 +26,4 @@ require (
 	go.opentelemetry.io/otel v0.6.0 // indirect
 	google.golang.org/grpc v1.56.3 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
-	gopkg.in/yaml.v2 v2.2.7 // indirect
 )
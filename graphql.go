@@ -0,0 +1,356 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/bxcodec/httpcache/cache"
+)
+
+// maxPersistedQueries is the max number of the learned persisted queries, see graphQLHandler.persisted
+const maxPersistedQueries = 10000
+
+// GraphQLOptions is the configuration of the GraphQL mode, see WithGraphQL
+type GraphQLOptions struct {
+	// Path is the glob of the GraphQL endpoint path, "/graphql" if empty
+	Path string
+	// Invalidate lists the query operation names purged after each successful mutation, by the mutation name,
+	// e.g {"UpdateProduct": {"GetProduct", "ListProducts"}}. It requires the cache storage to implement the
+	// cache.TagIndexer or the cache.KeyLister.
+	Invalidate map[string][]string
+	// MaxBodySize is the max size of the request body, the DefaultMaxBodyKeySize if zero.
+	// The request with a larger body is never served from nor stored to the cache storage.
+	MaxBodySize int64
+}
+
+// DefaultMaxGraphQLResponseSize is the max size of the GraphQL response checked for errors without
+// the max response size, see WithMaxResponseSize
+const DefaultMaxGraphQLResponseSize = 10 << 20
+
+// WithGraphQL will cache the GraphQL query operations sent to the endpoint, by POST or GET.
+// The key is built from the operation name, the normalized query (or the persisted query hash)
+// and the sorted variables. The mutations and the subscriptions are never cached, and the responses
+// with GraphQL errors, or too large to be checked, are never stored. The stored responses are tagged with GraphQLTag.
+func WithGraphQL(opts GraphQLOptions) Option {
+	return func(h *CacheHandler) {
+		h.graphQL = &graphQLHandler{GraphQLOptions: opts}
+	}
+}
+
+// GraphQLTag will return the tag of the stored responses of the query operation, e.g for PurgeTag
+func GraphQLTag(operationName string) string {
+	return "graphql:" + operationName
+}
+
+type graphQLHandler struct {
+	GraphQLOptions
+
+	mu sync.RWMutex
+	// persisted is the operation by the persisted query hash and the operation name, it's learned when
+	// both the query and its hash are sent, so the following requests with the hash only can be cached.
+	persisted map[string]graphQLDefinition
+}
+
+// graphQLOperation is the parsed GraphQL request
+type graphQLOperation struct {
+	Type          string // query, mutation or subscription
+	Name          string
+	QueryHash     string // The persisted query hash, or the hash of the normalized query
+	VariablesHash string
+}
+
+// graphQLRequest is the GraphQL request, over POST or GET: https://graphql.github.io/graphql-over-http/
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+	Extensions    struct {
+		PersistedQuery *struct {
+			Version    int    `json:"version"`
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// graphQLOperationKey is the context key of the parsed GraphQL operation
+type graphQLOperationKey struct{}
+
+func (g *graphQLHandler) match(req *http.Request) bool {
+	if req.Method != http.MethodPost && req.Method != http.MethodGet {
+		return false
+	}
+	pattern := g.Path
+	if pattern == "" {
+		pattern = "/graphql"
+	}
+	ok, _ := path.Match(pattern, req.URL.Path)
+	return ok
+}
+
+// parse will parse the GraphQL operation of the request. The returned request has a replayable
+// copy of the body, and the operation in its context. The operation is nil if it can't be parsed,
+// the skipped is the reason.
+func (g *graphQLHandler) parse(req *http.Request) (_ *http.Request, op *graphQLOperation, skipped, err error) {
+	var gqlReq graphQLRequest
+	if req.Method == http.MethodGet {
+		gqlReq, skipped = graphQLRequestFromQuery(req)
+	} else {
+		var body []byte
+		var ok bool
+		if req, body, ok, err = replayableBody(req, g.MaxBodySize); err != nil {
+			return nil, nil, nil, err
+		}
+		if !ok {
+			return req, nil, ErrRequestTooLarge, nil
+		}
+		skipped = json.Unmarshal(body, &gqlReq)
+	}
+	if skipped == nil {
+		op, skipped = g.operation(gqlReq)
+	}
+	if skipped != nil {
+		return req, nil, fmt.Errorf("%w: %v", ErrInvalidGraphQL, skipped), nil
+	}
+
+	ctx := context.WithValue(req.Context(), graphQLOperationKey{}, op)
+	if op.Type == GraphQLQuery && op.Name != "" {
+		ctx = WithTags(ctx, GraphQLTag(op.Name))
+	}
+	return req.WithContext(ctx), op, nil, nil
+}
+
+func (g *graphQLHandler) operation(gqlReq graphQLRequest) (*graphQLOperation, error) {
+	op := &graphQLOperation{Name: gqlReq.OperationName}
+	if variables := bytes.TrimSpace(gqlReq.Variables); len(variables) > 0 && !bytes.Equal(variables, []byte("null")) {
+		canonical, err := canonicalJSON(variables)
+		if err != nil {
+			return nil, err
+		}
+		op.VariablesHash = hashString(string(canonical))
+	}
+
+	var persistedHash string
+	if pq := gqlReq.Extensions.PersistedQuery; pq != nil {
+		persistedHash = strings.ToLower(pq.SHA256Hash)
+	}
+	if gqlReq.Query == "" {
+		if persistedHash == "" {
+			return nil, errGraphQLSyntax
+		}
+		// the automatic persisted query, the operation is only known if the query has been seen
+		g.mu.RLock()
+		definition, ok := g.persisted[persistedHash+" "+gqlReq.OperationName]
+		g.mu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown persisted query %s", errGraphQLUnknownOperation, persistedHash)
+		}
+		op.Type, op.Name = definition.kind, definition.name
+		op.QueryHash = persistedHash
+		return op, nil
+	}
+
+	normalized, definitions, err := normalizeGraphQL(gqlReq.Query)
+	if err != nil {
+		return nil, err
+	}
+	definition, err := selectGraphQLOperation(definitions, gqlReq.OperationName)
+	if err != nil {
+		return nil, err
+	}
+	op.Type, op.Name = definition.kind, definition.name
+	op.QueryHash = hashString(normalized)
+	if persistedHash != "" && persistedHash == hashString(gqlReq.Query) {
+		// the requests with the hash only will have the same key
+		op.QueryHash = persistedHash
+		g.learn(persistedHash+" "+gqlReq.OperationName, definition)
+	}
+	return op, nil
+}
+
+// learn will remember the operation of the persisted query
+func (g *graphQLHandler) learn(key string, definition graphQLDefinition) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.persisted == nil {
+		g.persisted = map[string]graphQLDefinition{}
+	}
+	if _, ok := g.persisted[key]; ok || len(g.persisted) >= maxPersistedQueries {
+		return
+	}
+	g.persisted[key] = definition
+}
+
+// roundTripGraphQL will send the GraphQL request to the upstream unless it's a query operation,
+// done is false if the returned request must go through the cache.
+func (r *CacheHandler) roundTripGraphQL(req *http.Request) (_ *http.Request, resp *http.Response, done bool, err error) {
+	req, op, skipped, err := r.graphQL.parse(req)
+	if err != nil {
+		return nil, nil, true, err
+	}
+	if skipped != nil {
+		r.emit(Event{Type: EventCacheSkipped, Key: r.CacheKey(req), Request: req, Err: skipped})
+		resp, err = r.sendUpstream(req)
+		return req, resp, true, err
+	}
+	if op.Type == GraphQLQuery {
+		return req, nil, false, nil
+	}
+
+	// the mutations and the subscriptions are never cached
+	resp, err = r.sendUpstream(req)
+	if err == nil && op.Type == GraphQLMutation && resp.StatusCode < http.StatusBadRequest {
+		r.invalidateGraphQL(req, op)
+	}
+	return req, resp, true, err
+}
+
+// roundTripGraphQLUnhealthy will send the GraphQL request to the upstream while the cache storage is
+// unhealthy, the queries invalidated by a mutation are purged once the storage is healthy again
+func (r *CacheHandler) roundTripGraphQLUnhealthy(req *http.Request) (*http.Response, error) {
+	req, op, skipped, err := r.graphQL.parse(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.sendUpstream(req)
+	if err == nil && skipped == nil && op.Type == GraphQLMutation && resp.StatusCode < http.StatusBadRequest {
+		r.invalidateGraphQL(req, op)
+	}
+	return resp, err
+}
+
+// graphQLQuery will return the GraphQL query operation of the request, if any
+func graphQLQuery(req *http.Request) (*graphQLOperation, bool) {
+	op, ok := req.Context().Value(graphQLOperationKey{}).(*graphQLOperation)
+	return op, ok && op.Type == GraphQLQuery
+}
+
+// invalidateGraphQL will purge the stored responses of the queries invalidated by the mutation,
+// they're purged once the storage is healthy again if it's unhealthy
+func (r *CacheHandler) invalidateGraphQL(req *http.Request, mutation *graphQLOperation) {
+	for _, name := range r.graphQL.Invalidate[mutation.Name] {
+		tag := GraphQLTag(name)
+		if r.deferPurge(tag) {
+			continue
+		}
+		_, err := r.PurgeTag(req.Context(), tag)
+		if err == nil {
+			continue
+		}
+		r.recordStorage(req, err)
+		if !r.deferPurge(tag) {
+			log.Printf("Can't purge the GraphQL query %s, please check. Err: %v\n", name, err)
+		}
+	}
+}
+
+// graphQLKey will return the key of the GraphQL query, it's the same whether it's sent by POST or GET
+func graphQLKey(req *http.Request, op *graphQLOperation, partition string) string {
+	// the GraphQL parameters of the GET request are part of the operation
+	u := *req.URL
+	query := u.Query()
+	for _, param := range []string{"query", "operationName", "variables", "extensions"} {
+		query.Del(param)
+	}
+	u.RawQuery, u.Fragment = query.Encode(), ""
+	key := fmt.Sprintf("GRAPHQL %s op=%s query=%s", u.String(), op.Name, op.QueryHash)
+	if op.VariablesHash != "" {
+		key = fmt.Sprintf("%s variables=%s", key, op.VariablesHash)
+	}
+	if partition != "" {
+		key = fmt.Sprintf("%s partition=%s", key, partition)
+	}
+	return key
+}
+
+// checkGraphQLErrors will check whether the GraphQL response has errors, the body stays readable, including
+// its read error. The response larger than the max size is never buffered, it fails with the ErrResponseTooLarge.
+func checkGraphQLErrors(resp *http.Response, maxSize int64) error {
+	if !isJSON(resp.Header.Get("Content-Type")) {
+		return nil
+	}
+	if maxSize == 0 {
+		maxSize = DefaultMaxGraphQLResponseSize
+	}
+	if resp.ContentLength > maxSize {
+		return ErrResponseTooLarge
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		resp.Body.Close()
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+		return err
+	}
+	if int64(len(body)) > maxSize {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return ErrResponseTooLarge
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var gqlResp struct {
+		Errors []json.RawMessage `json:"errors"`
+	}
+	if err = json.Unmarshal(body, &gqlResp); err != nil {
+		return nil
+	}
+	if len(gqlResp.Errors) > 0 {
+		return ErrGraphQLErrors
+	}
+	return nil
+}
+
+// errReader fails with the error, e.g the read error of a buffered body
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func graphQLRequestFromQuery(req *http.Request) (gqlReq graphQLRequest, err error) {
+	query := req.URL.Query()
+	gqlReq.Query = query.Get("query")
+	gqlReq.OperationName = query.Get("operationName")
+	if variables := query.Get("variables"); variables != "" {
+		gqlReq.Variables = json.RawMessage(variables)
+	}
+	if extensions := query.Get("extensions"); extensions != "" {
+		err = json.Unmarshal([]byte(extensions), &gqlReq.Extensions)
+	}
+	return
+}
+
+// validate will check the cache storage is able to purge by tag when the invalidation is configured
+func (g *graphQLHandler) validate(store cache.ICacheInteractor) error {
+	if g.MaxBodySize < 0 {
+		return fmt.Errorf("%w: the max body size must not be negative", ErrInvalidOption)
+	}
+	if len(g.Invalidate) == 0 {
+		return nil
+	}
+	_, indexer := store.(cache.TagIndexer)
+	_, lister := store.(cache.KeyLister)
+	if !indexer && !lister {
+		return fmt.Errorf("%w: the GraphQL invalidation requires the storage to purge by tag", ErrInvalidOption)
+	}
+	return nil
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,235 @@
+package httpcache_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+type graphQLPayload struct {
+	Query         string                 `json:"query,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func newGraphQLUpstream(t *testing.T, hits *int64) string {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Query().Get("fail") != "" {
+			_, _ = io.WriteString(w, `{"data":null,"errors":[{"message":"boom"}]}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"data":{"product":{"id":"1"}}}`)
+	})
+	return upstream.URL + "/graphql"
+}
+
+func postGraphQL(t *testing.T, client *http.Client, target string, payload graphQLPayload) {
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, target, strings.NewReader(string(body)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func TestGraphQL(t *testing.T) {
+	const query = `query GetProduct($id: ID!) { product(id: $id) { id } }`
+	persistedQuery := func(hash string) map[string]interface{} {
+		return map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash}}
+	}
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+
+	var hits int64
+	target := newGraphQLUpstream(t, &hits)
+	for _, tc := range []struct {
+		name         string
+		payloads     []graphQLPayload
+		expectedHits int64
+	}{
+		{
+			name: "normalized query",
+			payloads: []graphQLPayload{
+				{Query: query, Variables: map[string]interface{}{"id": "1", "locale": "en"}},
+				{
+					Query:     "# the product\nquery GetProduct($id: ID!) {\n  product(id: $id) {\n    id,\n  }\n}",
+					Variables: map[string]interface{}{"locale": "en", "id": "1"},
+				},
+			},
+			expectedHits: 1,
+		},
+		{
+			name: "different variables",
+			payloads: []graphQLPayload{
+				{Query: query, Variables: map[string]interface{}{"id": "1"}},
+				{Query: query, Variables: map[string]interface{}{"id": "2"}},
+			},
+			expectedHits: 2,
+		},
+		{
+			name: "selected operation",
+			payloads: []graphQLPayload{
+				{Query: query + ` query GetProducts { products { id } }`, OperationName: "GetProducts"},
+				{Query: query + ` query GetProducts { products { id } }`, OperationName: "GetProducts"},
+			},
+			expectedHits: 1,
+		},
+		{
+			name: "mutation",
+			payloads: []graphQLPayload{
+				{Query: `mutation UpdateProduct { updateProduct(id: 1) { id } }`},
+				{Query: `mutation UpdateProduct { updateProduct(id: 1) { id } }`},
+			},
+			expectedHits: 2,
+		},
+		{
+			name: "invalid query",
+			payloads: []graphQLPayload{
+				{Query: `query GetProduct { product(id: 1) { id }`},
+				{Query: `query GetProduct { product(id: 1) { id }`},
+			},
+			expectedHits: 2,
+		},
+		{
+			name: "persisted query",
+			payloads: []graphQLPayload{
+				{Extensions: persistedQuery(hash), Variables: map[string]interface{}{"id": "1"}},
+				{Query: query, Extensions: persistedQuery(hash), Variables: map[string]interface{}{"id": "1"}},
+				{Extensions: persistedQuery(hash), Variables: map[string]interface{}{"id": "1"}},
+			},
+			// the unknown hash is sent to the upstream, it's learned when the query is sent along
+			expectedHits: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt64(&hits, 0)
+			client := &http.Client{}
+			_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+				httpcache.WithGraphQL(httpcache.GraphQLOptions{}))
+			require.NoError(t, err)
+			for _, payload := range tc.payloads {
+				postGraphQL(t, client, target, payload)
+			}
+			require.Equal(t, tc.expectedHits, atomic.LoadInt64(&hits))
+		})
+	}
+}
+
+func TestGraphQLOverGET(t *testing.T) {
+	var hits int64
+	target := newGraphQLUpstream(t, &hits)
+	client := &http.Client{}
+	stats := &httpcache.Stats{}
+	_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithGraphQL(httpcache.GraphQLOptions{}), httpcache.WithObserver(stats))
+	require.NoError(t, err)
+
+	query := `{ product(id: 1) { id } }`
+	postGraphQL(t, client, target, graphQLPayload{Query: query})
+	// the same key, whether it's sent by POST or GET
+	doGet(t, client, target+"?"+url.Values{"query": {query}}.Encode())
+	require.EqualValues(t, 1, atomic.LoadInt64(&hits))
+
+	// the response with errors is never stored
+	doGet(t, client, target+"?"+url.Values{"query": {query}, "fail": {"1"}}.Encode())
+	doGet(t, client, target+"?"+url.Values{"query": {query}, "fail": {"1"}}.Encode())
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+	require.EqualValues(t, 2, stats.Count(httpcache.EventCacheSkipped))
+}
+
+func TestGraphQLInvalidate(t *testing.T) {
+	var hits int64
+	target := newGraphQLUpstream(t, &hits)
+	client := &http.Client{}
+	_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithGraphQL(httpcache.GraphQLOptions{
+			Invalidate: map[string][]string{"UpdateProduct": {"GetProduct"}},
+		}))
+	require.NoError(t, err)
+
+	getProduct := graphQLPayload{Query: `query GetProduct { product(id: 1) { id } }`}
+	getCategory := graphQLPayload{Query: `query GetCategory { category(id: 1) { id } }`}
+	postGraphQL(t, client, target, getProduct)
+	postGraphQL(t, client, target, getCategory)
+	postGraphQL(t, client, target, graphQLPayload{Query: `mutation UpdateProduct { updateProduct(id: 1) { id } }`})
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+	postGraphQL(t, client, target, getProduct)
+	postGraphQL(t, client, target, getCategory)
+	require.EqualValues(t, 4, atomic.LoadInt64(&hits))
+}
+
+func TestGraphQLInvalidateRequiresTags(t *testing.T) {
+	_, err := httpcache.New(&http.Client{}, NewCustomInMemStorage(),
+		httpcache.WithGraphQL(httpcache.GraphQLOptions{
+			Invalidate: map[string][]string{"UpdateProduct": {"GetProduct"}},
+		}))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+}
+
+func TestGraphQLResponseBody(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Query().Get("truncated") != "" {
+			// the connection is closed before the announced length is sent
+			w.Header().Set("Content-Length", "100")
+			_, _ = io.WriteString(w, `{"data":`)
+			return
+		}
+		// chunked, the size is unknown until it's read
+		_, _ = io.WriteString(w, `{"data":{"product":{"id":"`)
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, strings.Repeat("1", 200)+`"}}}`)
+	})
+	client := &http.Client{}
+	_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithGraphQL(httpcache.GraphQLOptions{}),
+		httpcache.WithMaxResponseSize(100))
+	require.NoError(t, err)
+	query := func(params string) (string, error) {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet,
+			upstream.URL+"/graphql?query="+url.QueryEscape(`query GetProduct { product(id: 1) { id } }`)+params,
+			http.NoBody)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	// the read error is returned to the caller
+	body, err := query("&truncated=1")
+	require.Error(t, err)
+	require.Equal(t, `{"data":`, body)
+
+	// the response larger than the max size is returned as is, it's never stored
+	for i := 0; i < 2; i++ {
+		body, err = query("")
+		require.NoError(t, err)
+		require.Len(t, body, len(`{"data":{"product":{"id":""}}}`)+200)
+	}
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+}
@@ -0,0 +1,170 @@
+package httpcache
+
+import (
+	"errors"
+	"strings"
+)
+
+// GraphQL operation types
+const (
+	GraphQLQuery        = "query"
+	GraphQLMutation     = "mutation"
+	GraphQLSubscription = "subscription"
+)
+
+var (
+	errGraphQLSyntax           = errors.New("invalid GraphQL document")
+	errGraphQLUnknownOperation = errors.New("GraphQL operation is not found")
+)
+
+// graphQLDefinition is a top level definition of a GraphQL document, e.g an operation or a fragment
+type graphQLDefinition struct {
+	kind string // query, mutation, subscription or fragment
+	name string
+}
+
+// normalizeGraphQL will return the tokens of the GraphQL document joined by a single space,
+// i.e without the comments, the commas and the insignificant spaces, along with its definitions.
+func normalizeGraphQL(document string) (normalized string, definitions []graphQLDefinition, err error) {
+	tokens, err := lexGraphQL(document)
+	if err != nil {
+		return "", nil, err
+	}
+
+	expectDefinition := true
+	braces, parens := 0, 0
+	for i, token := range tokens {
+		switch token {
+		case "{":
+			if expectDefinition && braces == 0 && parens == 0 {
+				// the query shorthand, e.g "{ product { id } }"
+				definitions = append(definitions, graphQLDefinition{kind: GraphQLQuery})
+				expectDefinition = false
+			}
+			braces++
+		case "}":
+			braces--
+			if braces == 0 && parens == 0 {
+				expectDefinition = true
+			}
+		case "(":
+			parens++
+		case ")":
+			parens--
+		case GraphQLQuery, GraphQLMutation, GraphQLSubscription, "fragment":
+			if !expectDefinition || braces != 0 || parens != 0 {
+				continue
+			}
+			definition := graphQLDefinition{kind: token}
+			if i+1 < len(tokens) && isGraphQLName(tokens[i+1]) {
+				definition.name = tokens[i+1]
+			}
+			definitions = append(definitions, definition)
+			expectDefinition = false
+		}
+		if braces < 0 || parens < 0 {
+			return "", nil, errGraphQLSyntax
+		}
+	}
+	if braces != 0 || parens != 0 {
+		return "", nil, errGraphQLSyntax
+	}
+	return strings.Join(tokens, " "), definitions, nil
+}
+
+// selectGraphQLOperation will select the executed operation of the document by its name,
+// the name is optional if the document has a single operation.
+func selectGraphQLOperation(definitions []graphQLDefinition, operationName string) (graphQLDefinition, error) {
+	var selected []graphQLDefinition
+	for _, definition := range definitions {
+		if definition.kind == "fragment" {
+			continue
+		}
+		if operationName == "" || definition.name == operationName {
+			selected = append(selected, definition)
+		}
+	}
+	if len(selected) != 1 {
+		return graphQLDefinition{}, errGraphQLUnknownOperation
+	}
+	return selected[0], nil
+}
+
+// lexGraphQL will split the GraphQL document into tokens: https://spec.graphql.org/October2021/#sec-Language.Source-Text
+func lexGraphQL(document string) (tokens []string, err error) {
+	for i := 0; i < len(document); {
+		c := document[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(document) && document[i] != '\n' && document[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(document[i:], "\uFEFF"):
+			i += len("\uFEFF")
+		case strings.HasPrefix(document[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case strings.IndexByte("!$&()=:@[]{}|", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		case strings.HasPrefix(document[i:], `"""`):
+			end := i + 3
+			for ; end < len(document) && !strings.HasPrefix(document[end:], `"""`); end++ {
+				if strings.HasPrefix(document[end:], `\"""`) {
+					end += 3
+				}
+			}
+			if end >= len(document) {
+				return nil, errGraphQLSyntax
+			}
+			tokens = append(tokens, document[i:end+3])
+			i = end + 3
+		case c == '"':
+			end := i + 1
+			for ; end < len(document) && document[end] != '"'; end++ {
+				if document[end] == '\\' {
+					end++
+				} else if document[end] == '\n' || document[end] == '\r' {
+					return nil, errGraphQLSyntax
+				}
+			}
+			if end >= len(document) {
+				return nil, errGraphQLSyntax
+			}
+			tokens = append(tokens, document[i:end+1])
+			i = end + 1
+		case isGraphQLNameStart(c):
+			end := i + 1
+			for end < len(document) && isGraphQLNameContinue(document[end]) {
+				end++
+			}
+			tokens = append(tokens, document[i:end])
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			// the int and float values, e.g "-1.5e+10"
+			end := i + 1
+			for end < len(document) && (isGraphQLNameContinue(document[end]) || strings.IndexByte(".+-", document[end]) >= 0) {
+				end++
+			}
+			tokens = append(tokens, document[i:end])
+			i = end
+		default:
+			return nil, errGraphQLSyntax
+		}
+	}
+	return tokens, nil
+}
+
+func isGraphQLName(token string) bool {
+	return token != "" && isGraphQLNameStart(token[0])
+}
+
+func isGraphQLNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isGraphQLNameContinue(c byte) bool {
+	return isGraphQLNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,68 @@
+package httpcache
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// withMethod will return a shallow copy of the request with the method
+func withMethod(req *http.Request, method string) *http.Request {
+	clone := new(http.Request)
+	*clone = *req
+	clone.Method = method
+	return clone
+}
+
+// updateFromHead will update the stored GET response with the HEAD response: https://tools.ietf.org/html/rfc7234#section-4.3.5
+// The stored response is marked as stale if the HEAD response reveals it's outdated, otherwise its headers
+// and freshness are updated. The HEAD response itself is never stored, and only the 2xx and 304 responses
+// are used, e.g a transient 503 never marks the stored response as stale.
+func (r *CacheHandler) updateFromHead(req *http.Request, resp *http.Response, rule *Rule) {
+	if (resp.StatusCode < 200 || resp.StatusCode > 299) && resp.StatusCode != http.StatusNotModified {
+		return
+	}
+	getReq := withMethod(req, http.MethodGet)
+	key := r.CacheKey(getReq)
+	stored, item, _, err := r.lookupCachedResponse(getReq, key)
+	if err != nil {
+		// nothing to update
+		return
+	}
+
+	if !sameRepresentation(stored, resp) {
+		item.ExpiresAt = time.Now()
+		if err = r.CacheInteractor.Set(key, item); err != nil {
+			log.Printf("Can't mark the stored response as stale, please check. Err: %v\n", err)
+		}
+		return
+	}
+
+	for name, values := range resp.Header {
+		if name == "Content-Length" {
+			continue
+		}
+		stored.Header[name] = values
+	}
+	r.cacheResponse(getReq, stored, rule)
+	discardBody(stored)
+}
+
+// sameRepresentation will compare the validators of the stored response and the HEAD response
+func sameRepresentation(stored, head *http.Response) bool {
+	if head.StatusCode != http.StatusNotModified && stored.StatusCode != head.StatusCode {
+		return false
+	}
+	for _, name := range []string{"ETag", "Last-Modified"} {
+		if v := head.Header.Get(name); v != "" && stored.Header.Get(name) != "" && v != stored.Header.Get(name) {
+			return false
+		}
+	}
+	if v := head.Header.Get("Content-Length"); v != "" && stored.ContentLength >= 0 {
+		if length, err := strconv.ParseInt(v, 10, 64); err == nil && length != stored.ContentLength {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package httpcache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadRequests(t *testing.T) {
+	var gets, heads, status int64
+	var etag, maxAge atomic.Value
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt64(&heads, 1)
+		} else {
+			atomic.AddInt64(&gets, 1)
+		}
+		w.Header().Set("Cache-Control", maxAge.Load().(string))
+		w.Header().Set("ETag", etag.Load().(string))
+		if code := atomic.LoadInt64(&status); code != 0 {
+			w.WriteHeader(int(code))
+		}
+		_, _ = io.WriteString(w, "hello")
+	})
+
+	do := func(client *http.Client, method, cacheControl string) (*http.Response, string) {
+		req, err := http.NewRequestWithContext(context.TODO(), method, upstream.URL, http.NoBody)
+		require.NoError(t, err)
+		if cacheControl != "" {
+			req.Header.Set("Cache-Control", cacheControl)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+	reset := func(tag, cacheControl string) *http.Client {
+		atomic.StoreInt64(&gets, 0)
+		atomic.StoreInt64(&heads, 0)
+		atomic.StoreInt64(&status, 0)
+		etag.Store(tag)
+		maxAge.Store(cacheControl)
+		client := &http.Client{}
+		_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}))
+		require.NoError(t, err)
+		return client
+	}
+
+	t.Run("answered from the stored GET", func(t *testing.T) {
+		client := reset(`"v1"`, "max-age=60")
+		// the HEAD response is never stored
+		do(client, http.MethodHead, "")
+		do(client, http.MethodGet, "")
+		resp, body := do(client, http.MethodHead, "")
+		require.Equal(t, "true", resp.Header.Get(httpcache.XFromHache))
+		require.Empty(t, body)
+		require.EqualValues(t, 5, resp.ContentLength)
+		require.EqualValues(t, 1, atomic.LoadInt64(&gets))
+		require.EqualValues(t, 1, atomic.LoadInt64(&heads))
+	})
+
+	t.Run("update the freshness of the stored GET", func(t *testing.T) {
+		client := reset(`"v1"`, "max-age=0")
+		do(client, http.MethodGet, "")
+
+		maxAge.Store("max-age=60")
+		do(client, http.MethodHead, "")
+		resp, body := do(client, http.MethodGet, "")
+		require.Equal(t, "true", resp.Header.Get(httpcache.XFromHache))
+		require.Equal(t, "max-age=60", resp.Header.Get("Cache-Control"))
+		require.Equal(t, "hello", body)
+		require.EqualValues(t, 1, atomic.LoadInt64(&gets))
+	})
+
+	t.Run("mark the stored GET as stale", func(t *testing.T) {
+		client := reset(`"v1"`, "max-age=60")
+		do(client, http.MethodGet, "")
+
+		etag.Store(`"v2"`)
+		do(client, http.MethodHead, "no-cache, no-store")
+		resp, _ := do(client, http.MethodGet, "")
+		require.Empty(t, resp.Header.Get(httpcache.XFromHache))
+		require.Equal(t, `"v2"`, resp.Header.Get("ETag"))
+		require.EqualValues(t, 2, atomic.LoadInt64(&gets))
+	})
+
+	t.Run("keep the stored GET after a failed HEAD", func(t *testing.T) {
+		client := reset(`"v1"`, "max-age=60")
+		do(client, http.MethodGet, "")
+
+		atomic.StoreInt64(&status, http.StatusServiceUnavailable)
+		etag.Store(`"v2"`)
+		resp, _ := do(client, http.MethodHead, "no-cache, no-store")
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		resp, body := do(client, http.MethodGet, "")
+		require.Equal(t, "true", resp.Header.Get(httpcache.XFromHache))
+		require.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+		require.Equal(t, "hello", body)
+		require.EqualValues(t, 1, atomic.LoadInt64(&gets))
+	})
+}
@@ -0,0 +1,243 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bxcodec/httpcache/cache"
+)
+
+// Default storage health options, see StorageHealthOptions
+const (
+	DefaultStorageFailureThreshold = 3
+	DefaultStorageBackoff          = 5 * time.Second
+	DefaultStorageMaxBackoff       = time.Minute
+)
+
+// healthProbeKey is the key read by the probe when the cache storage doesn't implement the cache.Pinger
+const healthProbeKey = "httpcache:health-probe"
+
+// StorageHealthOptions is the configuration of the storage health tracking, see WithStorageHealth
+type StorageHealthOptions struct {
+	// FailureThreshold is the number of consecutive cache.ErrStorageInternal marking the storage unhealthy,
+	// the DefaultStorageFailureThreshold if zero
+	FailureThreshold int `yaml:"failure_threshold"`
+	// Backoff is the time waited before the first probe of the unhealthy storage, it's doubled after
+	// each failed probe. The DefaultStorageBackoff if zero.
+	Backoff time.Duration `yaml:"backoff"`
+	// MaxBackoff is the max time waited between the probes, the DefaultStorageMaxBackoff if zero
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+// WithStorageHealth will track the health of the cache storage. The storage is unhealthy after consecutive
+// cache.ErrStorageInternal, then the cache is bypassed, i.e the requests are sent to the upstream and the
+// responses are never stored, until a probe in the background succeeds. The probe pings the storage if it
+// implements the cache.Pinger, otherwise it reads a key. The transitions are emitted as EventStorageUnhealthy
+// and EventStorageHealthy, and each bypassed request as EventStorageBypassed.
+func WithStorageHealth(opts StorageHealthOptions) Option {
+	if opts.FailureThreshold == 0 {
+		opts.FailureThreshold = DefaultStorageFailureThreshold
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = DefaultStorageBackoff
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = DefaultStorageMaxBackoff
+	}
+	return func(h *CacheHandler) {
+		h.health = &storageHealth{opts: opts, healthy: true}
+	}
+}
+
+type storageHealth struct {
+	opts     StorageHealthOptions
+	mu       sync.Mutex
+	healthy  bool
+	failures int
+	stop     chan struct{}       // Closed when the handler is closed, to stop the running probe, nil if healthy
+	pending  map[string]struct{} // The tags to purge once the storage is healthy again
+}
+
+func (h *storageHealth) validate() error {
+	if h.opts.FailureThreshold < 0 || h.opts.Backoff < 0 || h.opts.MaxBackoff < 0 {
+		return fmt.Errorf("%w: the storage health options must not be negative", ErrInvalidOption)
+	}
+	if h.opts.MaxBackoff < h.opts.Backoff {
+		return fmt.Errorf("%w: the storage max backoff must not be less than the backoff", ErrInvalidOption)
+	}
+	return nil
+}
+
+// record will record the outcome of a storage operation, the stop channel of the probe is returned
+// if the storage just became unhealthy
+func (h *storageHealth) record(err error) (stop <-chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.healthy {
+		return nil
+	}
+	if !errors.Is(err, cache.ErrStorageInternal) {
+		h.failures = 0
+		return nil
+	}
+	h.failures++
+	if h.failures < h.opts.FailureThreshold {
+		return nil
+	}
+	h.healthy = false
+	h.stop = make(chan struct{})
+	return h.stop
+}
+
+func (h *storageHealth) isHealthy() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.healthy
+}
+
+// deferPurge will keep the tag to purge it once the storage is healthy again, ok is false if it's healthy
+func (h *storageHealth) deferPurge(tag string) (ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.healthy {
+		return false
+	}
+	if h.pending == nil {
+		h.pending = map[string]struct{}{}
+	}
+	h.pending[tag] = struct{}{}
+	return true
+}
+
+func (h *storageHealth) pendingTags() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	tags := make([]string, 0, len(h.pending))
+	for tag := range h.pending {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// recover will mark the storage healthy once the purged tags were the last pending ones,
+// ok is false if other tags were deferred in the meantime
+func (h *storageHealth) recover(purged []string) (ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, tag := range purged {
+		delete(h.pending, tag)
+	}
+	if len(h.pending) > 0 {
+		return false
+	}
+	h.healthy, h.failures, h.stop = true, 0, nil
+	return true
+}
+
+// close will stop the running probe. The storage is healthy again, so the tracking starts over and
+// a new probe starts if it's still failing.
+func (h *storageHealth) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stop != nil {
+		close(h.stop)
+	}
+	h.healthy, h.failures, h.stop = true, 0, nil
+}
+
+// StorageHealthy will check whether the cache storage is healthy, it's always healthy without
+// the storage health tracking, see WithStorageHealth
+func (r *CacheHandler) StorageHealthy() bool {
+	return r.health == nil || r.health.isHealthy()
+}
+
+// recordStorage will record the outcome of a storage operation, the probe starts when the storage
+// becomes unhealthy
+func (r *CacheHandler) recordStorage(req *http.Request, err error) {
+	if r.health == nil {
+		return
+	}
+	stop := r.health.record(err)
+	if stop == nil {
+		return
+	}
+	log.Printf("The cache storage is unhealthy after %d failures, it's bypassed. Err: %v\n",
+		r.health.opts.FailureThreshold, err)
+	r.emit(Event{Type: EventStorageUnhealthy, Request: req, Err: err})
+	go r.probeStorage(stop)
+}
+
+// deferPurge will keep the tag to purge it once the unhealthy storage is healthy again,
+// ok is false if the storage is healthy
+func (r *CacheHandler) deferPurge(tag string) (ok bool) {
+	return r.health != nil && r.health.deferPurge(tag)
+}
+
+// probeStorage will probe the unhealthy storage with an exponential backoff, until it's healthy
+// or the probe is stopped
+func (r *CacheHandler) probeStorage(stop <-chan struct{}) {
+	backoff := r.health.opts.Backoff
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+		err := r.recoverStorage()
+		if err == nil {
+			log.Println("The cache storage is healthy again")
+			r.emit(Event{Type: EventStorageHealthy})
+			return
+		}
+		log.Printf("The cache storage is still unhealthy. Err: %v\n", err)
+		backoff *= 2
+		if backoff > r.health.opts.MaxBackoff {
+			backoff = r.health.opts.MaxBackoff
+		}
+		timer.Reset(backoff)
+	}
+}
+
+// recoverStorage will mark the storage healthy if it can be reached, after purging the tags deferred
+// while it was unhealthy, e.g the GraphQL queries invalidated by a mutation
+func (r *CacheHandler) recoverStorage() error {
+	if err := r.pingStorage(); err != nil {
+		return err
+	}
+	for {
+		tags := r.health.pendingTags()
+		for _, tag := range tags {
+			_, err := r.PurgeTag(context.Background(), tag)
+			if errors.Is(err, cache.ErrStorageInternal) {
+				return err
+			}
+			if err != nil {
+				log.Printf("Can't purge the tag %s, please check. Err: %v\n", tag, err)
+			}
+		}
+		if r.health.recover(tags) {
+			return nil
+		}
+	}
+}
+
+// pingStorage will check whether the storage can be reached, the missing probe key is a success
+func (r *CacheHandler) pingStorage() error {
+	if pinger, ok := r.CacheInteractor.(cache.Pinger); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		defer cancel()
+		return pinger.Ping(ctx)
+	}
+	_, err := r.CacheInteractor.Get(healthProbeKey)
+	if errors.Is(err, cache.ErrStorageInternal) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStorage fails with the cache.ErrStorageInternal while it's failing
+type failingStorage struct {
+	cache.ICacheInteractor
+	failing int64
+	calls   int64
+}
+
+func (s *failingStorage) Get(key string) (cache.CachedResponse, error) {
+	atomic.AddInt64(&s.calls, 1)
+	if atomic.LoadInt64(&s.failing) == 1 {
+		return cache.CachedResponse{}, cache.ErrStorageInternal
+	}
+	return s.ICacheInteractor.Get(key)
+}
+
+func (s *failingStorage) Set(key string, value cache.CachedResponse) error {
+	atomic.AddInt64(&s.calls, 1)
+	if atomic.LoadInt64(&s.failing) == 1 {
+		return cache.ErrStorageInternal
+	}
+	return s.ICacheInteractor.Set(key, value)
+}
+
+func TestStorageHealth(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	store := &failingStorage{ICacheInteractor: inmem.NewBoundedCache(inmem.BoundedCacheOptions{})}
+	client := &http.Client{}
+	stats := &httpcache.Stats{}
+	handler, err := httpcache.New(client, store, httpcache.WithObserver(stats),
+		httpcache.WithStorageHealth(httpcache.StorageHealthOptions{
+			FailureThreshold: 2,
+			Backoff:          100 * time.Millisecond,
+		}))
+	require.NoError(t, err)
+	defer handler.Close(context.TODO())
+
+	doGet(t, client, upstream.URL+"/1")
+	require.True(t, handler.StorageHealthy())
+
+	// the Get and the Set of the same request fail
+	atomic.StoreInt64(&store.failing, 1)
+	_, body := doGet(t, client, upstream.URL+"/2")
+	require.Equal(t, "/2", body)
+	require.False(t, handler.StorageHealthy())
+	require.EqualValues(t, 1, stats.Count(httpcache.EventStorageUnhealthy))
+
+	// the storage is bypassed
+	calls := atomic.LoadInt64(&store.calls)
+	_, body = doGet(t, client, upstream.URL+"/1")
+	require.Equal(t, "/1", body)
+	require.Equal(t, calls, atomic.LoadInt64(&store.calls))
+	require.EqualValues(t, 1, stats.Count(httpcache.EventStorageBypassed))
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+	// the failed probe keeps it unhealthy
+	time.Sleep(150 * time.Millisecond)
+	require.False(t, handler.StorageHealthy())
+	require.Greater(t, atomic.LoadInt64(&store.calls), calls)
+
+	// the successful probe recovers it
+	atomic.StoreInt64(&store.failing, 0)
+	require.Eventually(t, func() bool {
+		return stats.Count(httpcache.EventStorageHealthy) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.True(t, handler.StorageHealthy())
+	doGet(t, client, upstream.URL+"/1")
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+}
+
+func TestStorageHealthInvalidOptions(t *testing.T) {
+	_, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithStorageHealth(httpcache.StorageHealthOptions{Backoff: time.Minute, MaxBackoff: time.Second}))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+}
+
+// failingTagStorage is the failingStorage indexing the tags
+type failingTagStorage struct {
+	*failingStorage
+	cache.TagIndexer
+}
+
+func TestStorageHealthGraphQLInvalidate(t *testing.T) {
+	var hits int64
+	target := newGraphQLUpstream(t, &hits)
+	bounded := inmem.NewBoundedCache(inmem.BoundedCacheOptions{})
+	store := &failingStorage{ICacheInteractor: bounded}
+	client := &http.Client{}
+	stats := &httpcache.Stats{}
+	handler, err := httpcache.New(client, failingTagStorage{failingStorage: store, TagIndexer: bounded},
+		httpcache.WithObserver(stats),
+		httpcache.WithGraphQL(httpcache.GraphQLOptions{
+			Invalidate: map[string][]string{"UpdateProduct": {"GetProduct"}},
+		}),
+		httpcache.WithStorageHealth(httpcache.StorageHealthOptions{
+			FailureThreshold: 1,
+			Backoff:          50 * time.Millisecond,
+		}))
+	require.NoError(t, err)
+	defer handler.Close(context.TODO())
+
+	getProduct := graphQLPayload{Query: `query GetProduct { product(id: 1) { id } }`}
+	postGraphQL(t, client, target, getProduct)
+	postGraphQL(t, client, target, getProduct)
+	require.EqualValues(t, 1, atomic.LoadInt64(&hits))
+
+	atomic.StoreInt64(&store.failing, 1)
+	postGraphQL(t, client, target, graphQLPayload{Query: `query GetCategory { category(id: 1) { id } }`})
+	require.False(t, handler.StorageHealthy())
+
+	// the mutation is sent while the storage is unhealthy, the query is purged once it's healthy again
+	postGraphQL(t, client, target, graphQLPayload{Query: `mutation UpdateProduct { updateProduct(id: 1) { id } }`})
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+	atomic.StoreInt64(&store.failing, 0)
+	require.Eventually(t, handler.StorageHealthy, time.Second, 10*time.Millisecond)
+
+	postGraphQL(t, client, target, getProduct)
+	require.EqualValues(t, 4, atomic.LoadInt64(&hits))
+}
+
+func TestStorageHealthAfterClose(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	store := &failingStorage{ICacheInteractor: inmem.NewBoundedCache(inmem.BoundedCacheOptions{})}
+	client := &http.Client{}
+	stats := &httpcache.Stats{}
+	handler, err := httpcache.New(client, store, httpcache.WithObserver(stats),
+		httpcache.WithStorageHealth(httpcache.StorageHealthOptions{
+			FailureThreshold: 1,
+			Backoff:          50 * time.Millisecond,
+		}))
+	require.NoError(t, err)
+
+	atomic.StoreInt64(&store.failing, 1)
+	doGet(t, client, upstream.URL+"/1")
+	require.False(t, handler.StorageHealthy())
+
+	// the storage is tried again after the handler is closed
+	require.NoError(t, handler.Close(context.TODO()))
+	require.True(t, handler.StorageHealthy())
+
+	// and it's probed again once it's unhealthy
+	doGet(t, client, upstream.URL+"/1")
+	require.False(t, handler.StorageHealthy())
+	atomic.StoreInt64(&store.failing, 0)
+	require.Eventually(t, handler.StorageHealthy, time.Second, 10*time.Millisecond)
+	require.EqualValues(t, 2, stats.Count(httpcache.EventStorageUnhealthy))
+	require.EqualValues(t, 1, stats.Count(httpcache.EventStorageHealthy))
+
+	doGet(t, client, upstream.URL+"/1")
+	doGet(t, client, upstream.URL+"/1")
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+	require.NoError(t, handler.Close(context.TODO()))
+}
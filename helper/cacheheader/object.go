@@ -19,6 +19,7 @@ package cacheheader
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -42,6 +43,28 @@ type Object struct {
 	ReqMethod     string
 
 	NowUTC time.Time
+
+	// Heuristic is used when the response has no explicit expiration time, the DefaultHeuristic if nil
+	Heuristic *Heuristic
+}
+
+// Heuristic is the configuration of the heuristic freshness lifetime: http://tools.ietf.org/html/rfc7234#section-4.2.2
+type Heuristic struct {
+	// LastModifiedFactor is the fraction of the time since the Last-Modified used as the lifetime,
+	// 0.1 if zero. A negative factor disables the Last-Modified heuristic.
+	LastModifiedFactor float64
+	// MaxLifetime caps the lifetime calculated from the Last-Modified, 24 hours if zero
+	MaxLifetime time.Duration
+	// StatusLifetimes is the lifetime per status code used when the Last-Modified is missing,
+	// only for the status codes cachable by default (see CachableStatusCode).
+	StatusLifetimes map[int]time.Duration
+}
+
+// DefaultHeuristic is the heuristic of the Apache mod_cache:
+// http://httpd.apache.org/docs/2.4/mod/mod_cache.html#cachelastmodifiedfactor
+var DefaultHeuristic = Heuristic{
+	LastModifiedFactor: 0.1,
+	MaxLifetime:        twentyFourHoursDuration,
 }
 
 // ObjectResults is represents the results of examinig an Object with
@@ -109,8 +132,9 @@ func CachableObject(obj *Object, rv *ObjectResults) {
 	}
 
 	// Storing Responses to Authenticated Requests: http://tools.ietf.org/html/rfc7234#section-3.2
+	// The requirement only applies to shared caches.
 	authz := obj.ReqHeaders.Get("Authorization")
-	if authz != "" {
+	if authz != "" && !obj.CacheIsPrivate {
 		if obj.RespDirectives.MustRevalidate ||
 			obj.RespDirectives.Public ||
 			obj.RespDirectives.SMaxAge != -1 {
@@ -192,32 +216,19 @@ func ExpirationObject(obj *Object, rv *ObjectResults) {
 			serverDate = obj.NowUTC
 		}
 		expiresTime = obj.NowUTC.Add(obj.RespExpiresHeader.Sub(serverDate))
-	} else if !obj.RespLastModifiedHeader.IsZero() {
-		// heuristic freshness lifetime
-		rv.OutWarnings = append(rv.OutWarnings, WarningHeuristicExpiration)
-
-		// http://httpd.apache.org/docs/2.4/mod/mod_cache.html#cachelastmodifiedfactor
-		// CacheMaxExpire defaults to 24 hours
-		// CacheLastModifiedFactor: is 0.1
-		//
-		// expiry-period = MIN(time-since-last-modified-date * factor, 24 hours)
-		//
-		// obj.NowUTC
-
-		since := obj.RespLastModifiedHeader.Sub(obj.NowUTC)
-		since = time.Duration(float64(since) * -0.1)
-
-		if since > twentyFourHoursDuration {
-			expiresTime = obj.NowUTC.Add(twentyFourHoursDuration)
-		} else {
-			expiresTime = obj.NowUTC.Add(since)
+	} else if lifetime, ok := heuristicLifetime(obj); ok {
+		expiresTime = obj.NowUTC.Add(lifetime)
+
+		// http://tools.ietf.org/html/rfc7234#section-5.5.4
+		// Only when the heuristic lifetime is greater than 24 hours and the response's age is greater than 24 hours.
+		if lifetime > twentyFourHoursDuration && currentAge(obj) > twentyFourHoursDuration {
+			rv.OutWarnings = append(rv.OutWarnings, WarningHeuristicExpiration)
 		}
 
 		if debug {
 			println("Now UTC: ", obj.NowUTC.String())
 			println("Last-Modified: ", obj.RespLastModifiedHeader.String())
-			println("Since: ", since.String())
-			println("TwentyFourHours: ", twentyFourHoursDuration.String())
+			println("Lifetime: ", lifetime.String())
 			println("Expiration: ", expiresTime.String())
 		}
 	}
@@ -225,6 +236,51 @@ func ExpirationObject(obj *Object, rv *ObjectResults) {
 	rv.OutExpirationTime = expiresTime
 }
 
+// heuristicLifetime will calculate the heuristic freshness lifetime, if applicable
+func heuristicLifetime(obj *Object) (time.Duration, bool) {
+	heuristic := obj.Heuristic
+	if heuristic == nil {
+		heuristic = &DefaultHeuristic
+	}
+	factor := heuristic.LastModifiedFactor
+	if factor == 0 {
+		factor = DefaultHeuristic.LastModifiedFactor
+	}
+	maxLifetime := heuristic.MaxLifetime
+	if maxLifetime == 0 {
+		maxLifetime = DefaultHeuristic.MaxLifetime
+	}
+
+	// expiry-period = MIN(time-since-last-modified-date * factor, max-lifetime)
+	if !obj.RespLastModifiedHeader.IsZero() && factor > 0 {
+		since := obj.NowUTC.Sub(obj.RespLastModifiedHeader)
+		lifetime := time.Duration(float64(since) * factor)
+		if lifetime > maxLifetime {
+			lifetime = maxLifetime
+		}
+		return lifetime, true
+	}
+
+	if lifetime, ok := heuristic.StatusLifetimes[obj.RespStatusCode]; ok && CachableStatusCode(obj.RespStatusCode) {
+		return lifetime, true
+	}
+	return 0, false
+}
+
+// currentAge will calculate the age of the response: http://tools.ietf.org/html/rfc7234#section-4.2.3
+func currentAge(obj *Object) (age time.Duration) {
+	if !obj.RespDateHeader.IsZero() && obj.NowUTC.After(obj.RespDateHeader) {
+		age = obj.NowUTC.Sub(obj.RespDateHeader)
+	}
+	if obj.RespHeaders != nil {
+		if seconds, err := strconv.ParseUint(obj.RespHeaders.Get("Age"), 10, 32); err == nil &&
+			time.Duration(seconds)*time.Second > age {
+			age = time.Duration(seconds) * time.Second
+		}
+	}
+	return age
+}
+
 // UsingRequestResponse will Evaluate cachability based on an HTTP request, and parts of the response.
 func UsingRequestResponse(req *http.Request,
 	statusCode int,
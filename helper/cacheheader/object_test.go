@@ -283,6 +283,19 @@ func TestCachableAuthorization(t *testing.T) {
 	require.Len(t, rv.OutReasons, 0)
 }
 
+func TestAuthorizationWithPrivateCache(t *testing.T) {
+	now := time.Now().UTC()
+
+	obj := fill(t, now)
+	obj.CacheIsPrivate = true
+	obj.ReqHeaders.Set("Authorization", "bearer random")
+
+	rv := cacheControl.ObjectResults{}
+	cacheControl.CachableObject(&obj, &rv)
+	require.NoError(t, rv.OutErr)
+	require.Len(t, rv.OutReasons, 0)
+}
+
 func TestRespNoStore(t *testing.T) {
 	now := time.Now().UTC()
 
@@ -396,3 +409,82 @@ func TestExpirationExpiresNoServerDate(t *testing.T) {
 	require.Len(t, rv.OutWarnings, 0)
 	require.WithinDuration(t, now.Add(time.Second*1500), rv.OutExpirationTime, time.Second*1)
 }
+
+func TestExpirationHeuristic(t *testing.T) {
+	now := time.Now().UTC()
+	const day = 24 * time.Hour
+
+	for _, tc := range []struct {
+		name             string
+		heuristic        *cacheControl.Heuristic
+		lastModified     time.Time
+		date             time.Time
+		statusCode       int
+		expectedLifetime time.Duration
+		expectedWarnings int
+	}{
+		{
+			name:             "default factor",
+			lastModified:     now.Add(-10 * time.Hour),
+			expectedLifetime: time.Hour,
+		},
+		{
+			name:             "default max lifetime",
+			lastModified:     now.Add(-100 * day),
+			expectedLifetime: day,
+		},
+		{
+			name:             "custom factor and max lifetime",
+			heuristic:        &cacheControl.Heuristic{LastModifiedFactor: 0.5, MaxLifetime: 30 * day},
+			lastModified:     now.Add(-100 * day),
+			expectedLifetime: 30 * day,
+		},
+		{
+			name:             "heuristic expiration warning",
+			heuristic:        &cacheControl.Heuristic{MaxLifetime: 30 * day},
+			lastModified:     now.Add(-100 * day),
+			date:             now.Add(-2 * day),
+			expectedLifetime: 10 * day,
+			expectedWarnings: 1,
+		},
+		{
+			name:             "no warning when the age is less than 24 hours",
+			heuristic:        &cacheControl.Heuristic{MaxLifetime: 30 * day},
+			lastModified:     now.Add(-100 * day),
+			expectedLifetime: 10 * day,
+		},
+		{
+			name:             "status lifetime",
+			heuristic:        &cacheControl.Heuristic{StatusLifetimes: map[int]time.Duration{http.StatusNotFound: time.Minute}},
+			statusCode:       http.StatusNotFound,
+			expectedLifetime: time.Minute,
+		},
+		{
+			name:             "last modified disabled",
+			heuristic:        &cacheControl.Heuristic{LastModifiedFactor: -1},
+			lastModified:     now.Add(-10 * time.Hour),
+			expectedLifetime: 0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := fill(t, now)
+			obj.Heuristic = tc.heuristic
+			obj.RespLastModifiedHeader = tc.lastModified
+			if !tc.date.IsZero() {
+				obj.RespDateHeader = tc.date
+			}
+			if tc.statusCode != 0 {
+				obj.RespStatusCode = tc.statusCode
+			}
+
+			rv := cacheControl.ObjectResults{}
+			cacheControl.ExpirationObject(&obj, &rv)
+			require.Len(t, rv.OutWarnings, tc.expectedWarnings)
+			if tc.expectedLifetime == 0 {
+				require.True(t, rv.OutExpirationTime.IsZero())
+				return
+			}
+			require.WithinDuration(t, now.Add(tc.expectedLifetime), rv.OutExpirationTime, time.Second)
+		})
+	}
+}
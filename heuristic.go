@@ -0,0 +1,72 @@
+package httpcache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+)
+
+// WithHeuristic will set how the freshness lifetime is calculated when the response has no explicit
+// expiration time, it's the cacheheader.DefaultHeuristic by default.
+func WithHeuristic(heuristic cacheControl.Heuristic) Option {
+	return WithHostHeuristic("", heuristic)
+}
+
+// WithHostHeuristic will override the heuristic for the requests to the host, e.g "api.example.com"
+func WithHostHeuristic(host string, heuristic cacheControl.Heuristic) Option {
+	return func(h *CacheHandler) {
+		if h.heuristics == nil {
+			h.heuristics = map[string]cacheControl.Heuristic{}
+		}
+		h.heuristics[strings.ToLower(host)] = heuristic
+	}
+}
+
+// heuristic will return the heuristic for the request, or nil for the default one
+func (r *CacheHandler) heuristic(req *http.Request) *cacheControl.Heuristic {
+	if len(r.heuristics) == 0 {
+		return nil
+	}
+	host := req.URL.Hostname()
+	if host == "" {
+		host = req.Host
+	}
+	if heuristic, ok := r.heuristics[strings.ToLower(host)]; ok {
+		return &heuristic
+	}
+	if heuristic, ok := r.heuristics[""]; ok {
+		return &heuristic
+	}
+	return nil
+}
+
+// addHeuristicWarning will add the warnings of the served response, i.e the Warning 113 when its heuristic
+// freshness lifetime and its age are greater than 24 hours.
+func (r *CacheHandler) addHeuristicWarning(req *http.Request, resp *http.Response, now time.Time) {
+	validationResult, err := r.validateTheCacheControl(req, resp, now)
+	if err != nil {
+		return
+	}
+	for _, warning := range validationResult.OutWarnings {
+		resp.Header.Add(HeaderWarning, warning.HeaderString("", now))
+	}
+}
+
+func validateHeuristic(heuristic cacheControl.Heuristic) error {
+	if heuristic.MaxLifetime < 0 {
+		return errors.New("the max lifetime must not be negative")
+	}
+	for status, lifetime := range heuristic.StatusLifetimes {
+		if lifetime < 0 {
+			return errors.New("the lifetime must not be negative")
+		}
+		if !cacheControl.CachableStatusCode(status) {
+			return fmt.Errorf("the status code %d is not cachable by default", status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package httpcache_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHeuristic(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		if r.URL.Path == "/old" {
+			w.Header().Set("Date", time.Now().Add(-48*time.Hour).UTC().Format(http.TimeFormat))
+			w.Header().Set("Last-Modified", time.Now().Add(-100*24*time.Hour).UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, "not found")
+	})
+
+	client := &http.Client{}
+	_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithHeuristic(cacheControl.Heuristic{
+			MaxLifetime:     30 * 24 * time.Hour,
+			StatusLifetimes: map[int]time.Duration{http.StatusNotFound: time.Minute},
+		}),
+		// the heuristic is not applied to the other host
+		httpcache.WithHostHeuristic("localhost", cacheControl.Heuristic{}),
+	)
+	require.NoError(t, err)
+
+	doGet(t, client, upstream.URL+"/missing")
+	resp, _ := doGet(t, client, upstream.URL+"/missing")
+	require.Equal(t, "true", resp.Header.Get(httpcache.XFromHache))
+	require.EqualValues(t, 1, atomic.LoadInt64(&hits))
+
+	// the heuristic lifetime is 10 days, and the response is 2 days old
+	doGet(t, client, upstream.URL+"/old")
+	resp, _ = doGet(t, client, upstream.URL+"/old")
+	require.Equal(t, "true", resp.Header.Get(httpcache.XFromHache))
+	require.True(t, strings.HasPrefix(resp.Header.Get(httpcache.HeaderWarning), "113 "))
+
+	resp, _ = doGet(t, client, strings.Replace(upstream.URL, "127.0.0.1", "localhost", 1)+"/missing")
+	require.Empty(t, resp.Header.Get(httpcache.XFromHache))
+
+	_, err = httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithHeuristic(cacheControl.Heuristic{StatusLifetimes: map[int]time.Duration{http.StatusBadGateway: time.Minute}}))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+}
@@ -1,34 +1,48 @@
 package httpcache
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/bxcodec/gotcha"
 	inmemcache "github.com/bxcodec/gotcha/cache"
 	"github.com/bxcodec/httpcache/cache"
+	"github.com/bxcodec/httpcache/cache/disk"
 	"github.com/bxcodec/httpcache/cache/inmem"
 	rediscache "github.com/bxcodec/httpcache/cache/redis"
 	"github.com/go-redis/redis/v8"
 	"golang.org/x/net/context"
 )
 
+// New will inject the cache to the HTTP client using the cache storage, the handler is configured with the options.
+// The RFC 7234 compliance is enabled by default. The client's Transport is used to send the requests
+// that can't be served from the cache, unless it's overridden by WithTransport.
+//
+// The error is returned if the configuration is invalid, or if the cache storage implements the
+// cache.Pinger and it can't be reached.
+func New(client *http.Client, cacheInteractor cache.ICacheInteractor, opts ...Option) (cachedHandler *CacheHandler, err error) {
+	if client == nil {
+		return nil, ErrNilClient
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	cachedHandler, err = newCacheHandler(transport, cacheInteractor, opts...)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = cachedHandler
+	return cachedHandler, nil
+}
+
 // NewWithCustomStorageCache will initiate the httpcache with your defined cache storage
 // To use your own cache storage handler, you need to implement the cache.Interactor interface
 // And pass it to httpcache.
 func NewWithCustomStorageCache(client *http.Client, rfcCompliance bool,
 	cacheInteractor cache.ICacheInteractor) (cacheHandler *CacheHandler, err error) {
-	return newClient(client, rfcCompliance, cacheInteractor)
-}
-
-func newClient(client *http.Client, rfcCompliance bool,
-	cacheInteractor cache.ICacheInteractor) (cachedHandler *CacheHandler, err error) {
-	if client.Transport == nil {
-		client.Transport = http.DefaultTransport
-	}
-	cachedHandler = NewCacheHandlerRoundtrip(client.Transport, rfcCompliance, cacheInteractor)
-	client.Transport = cachedHandler
-	return
+	return New(client, cacheInteractor, WithRFCCompliance(rfcCompliance))
 }
 
 const (
@@ -47,13 +61,25 @@ func NewWithInmemoryCache(client *http.Client, rfcCompliance bool, duration ...t
 			SetExpiryTime(expiryTime).SetMaxSizeItem(MaxSizeCacheItem),
 	)
 
-	return newClient(client, rfcCompliance, inmem.NewCache(c))
+	return New(client, inmem.NewCache(c), WithRFCCompliance(rfcCompliance))
+}
+
+// NewWithBoundedInmemoryCache will create a complete cache-support of HTTP client with using inmemory cache
+// bounded by the total size of the stored responses instead of the number of items.
+// Use the inmem.BoundedCache.Stats of the handler.CacheInteractor for monitoring the size and evictions.
+func NewWithBoundedInmemoryCache(client *http.Client, rfcCompliance bool,
+	options inmem.BoundedCacheOptions) (cachedHandler *CacheHandler, err error) {
+	return New(client, inmem.NewBoundedCache(options), WithRFCCompliance(rfcCompliance))
 }
 
 // NewWithRedisCache will create a complete cache-support of HTTP client with using redis cache.
-// If the duration not set, the cache will use LFU algorithm
+// If the duration not set, the cache will use LFU algorithm.
+// The ErrStorageUnreachable is returned if the redis can't be reached.
 func NewWithRedisCache(client *http.Client, rfcCompliance bool, options *rediscache.CacheOptions,
 	duration ...time.Duration) (cachedHandler *CacheHandler, err error) {
+	if options == nil {
+		return nil, fmt.Errorf("%w: the redis options must be set", ErrInvalidOption)
+	}
 	var ctx = context.Background()
 	var expiryTime time.Duration
 	if len(duration) > 0 {
@@ -65,5 +91,22 @@ func NewWithRedisCache(client *http.Client, rfcCompliance bool, options *redisca
 		DB:       options.DB,
 	})
 
-	return newClient(client, rfcCompliance, rediscache.NewCache(ctx, c, expiryTime))
+	cachedHandler, err = New(client, rediscache.NewCache(ctx, c, expiryTime), WithRFCCompliance(rfcCompliance))
+	if err != nil {
+		// release the connection pool, the client is never used
+		_ = c.Close()
+		return nil, err
+	}
+	return cachedHandler, nil
+}
+
+// NewWithDiskCache will create a complete cache-support of HTTP client with using disk cache.
+// Each response is stored as a file inside the dir. If the duration not set, the item will never expire.
+func NewWithDiskCache(client *http.Client, rfcCompliance bool, dir string,
+	duration ...time.Duration) (cachedHandler *CacheHandler, err error) {
+	var expiryTime time.Duration
+	if len(duration) > 0 {
+		expiryTime = duration[0]
+	}
+	return New(client, disk.NewCache(dir, expiryTime), WithRFCCompliance(rfcCompliance))
 }
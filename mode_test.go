@@ -0,0 +1,73 @@
+package httpcache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheMode(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/shared":
+			// stale right away for a private cache
+			w.Header().Set("Cache-Control", "max-age=0, s-maxage=60")
+		case "/auth":
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		_, _ = io.WriteString(w, r.Header.Get("Authorization"))
+	})
+
+	get := func(client *http.Client, path, auth string) string {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, upstream.URL+path, http.NoBody)
+		require.NoError(t, err)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	for _, tc := range []struct {
+		mode         httpcache.CacheMode
+		path         string
+		auths        []string
+		expectedHits int64
+	}{
+		{mode: httpcache.SharedCache, path: "/private", auths: []string{"", ""}, expectedHits: 2},
+		{mode: httpcache.PrivateCache, path: "/private", auths: []string{"", ""}, expectedHits: 1},
+		{mode: httpcache.SharedCache, path: "/shared", auths: []string{"", ""}, expectedHits: 1},
+		{mode: httpcache.PrivateCache, path: "/shared", auths: []string{"", ""}, expectedHits: 2},
+		{mode: httpcache.SharedCache, path: "/auth", auths: []string{"alice", "alice"}, expectedHits: 2},
+		{mode: httpcache.PrivateCache, path: "/auth", auths: []string{"alice", "alice", "bob"}, expectedHits: 2},
+	} {
+		t.Run(tc.mode.String()+tc.path, func(t *testing.T) {
+			atomic.StoreInt64(&hits, 0)
+			client := &http.Client{}
+			handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+				httpcache.WithCacheMode(tc.mode))
+			require.NoError(t, err)
+			require.Equal(t, tc.mode, handler.Mode())
+
+			for _, auth := range tc.auths {
+				// the stored responses are never served across the credentials
+				require.Equal(t, auth, get(client, tc.path, auth))
+			}
+			require.Equal(t, tc.expectedHits, atomic.LoadInt64(&hits))
+		})
+	}
+}
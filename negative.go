@@ -0,0 +1,57 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultNegativeTTL is the TTL of the error responses without a configured TTL nor a rule TTL when the
+// RFC 7234 compliance is disabled, see WithNegativeCaching
+const DefaultNegativeTTL = 10 * time.Second
+
+// WithNegativeCaching will cache the error responses, i.e the status code 400 and above, for the TTL
+// of their status code regardless their headers, e.g {404: 30 * time.Second, 503: 0}. A zero TTL
+// means the status is never cached. The 5xx responses are never cached unless listed with a positive TTL.
+// Without the RFC 7234 compliance, the other 4xx responses are cached for the DefaultNegativeTTL.
+func WithNegativeCaching(ttls map[int]time.Duration) Option {
+	return func(h *CacheHandler) {
+		if h.negativeTTLs == nil {
+			h.negativeTTLs = map[int]time.Duration{}
+		}
+		for status, ttl := range ttls {
+			h.negativeTTLs[status] = ttl
+		}
+	}
+}
+
+// negativeTTL will return the TTL of the error response, ok is false if it's not configured
+func (r *CacheHandler) negativeTTL(status int) (ttl time.Duration, ok bool) {
+	ttl, ok = r.negativeTTLs[status]
+	return
+}
+
+// cachableStatus will check whether the response status can be stored, regardless the headers
+func (r *CacheHandler) cachableStatus(status int) error {
+	if status == http.StatusPartialContent {
+		// the partial response must not be served as if it's the full representation
+		return fmt.Errorf("%w: %d", ErrUncachableStatus, status)
+	}
+	ttl, ok := r.negativeTTL(status)
+	if (ok && ttl == 0) || (!ok && status >= http.StatusInternalServerError) {
+		return fmt.Errorf("%w: %d", ErrUncachableStatus, status)
+	}
+	return nil
+}
+
+func validateNegativeTTLs(ttls map[int]time.Duration) error {
+	for status, ttl := range ttls {
+		if status < http.StatusBadRequest || status > 599 {
+			return fmt.Errorf("%w: status %d is not an error status", ErrInvalidOption, status)
+		}
+		if ttl < 0 {
+			return fmt.Errorf("%w: the ttl of status %d must not be negative", ErrInvalidOption, status)
+		}
+	}
+	return nil
+}
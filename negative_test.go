@@ -0,0 +1,98 @@
+package httpcache_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegativeCaching(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		status, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, http.StatusText(status))
+	})
+
+	for _, tc := range []struct {
+		name         string
+		rfc          bool
+		ttls         map[int]time.Duration
+		status       int
+		expectedHits int64
+		negativeHits uint64
+	}{
+		{name: "5xx without rfc", status: http.StatusInternalServerError, expectedHits: 2},
+		{name: "4xx without rfc", status: http.StatusNotFound, expectedHits: 1, negativeHits: 1},
+		{name: "never cached", status: http.StatusNotFound, ttls: map[int]time.Duration{404: 0}, expectedHits: 2},
+		{name: "expired ttl", status: http.StatusNotFound, ttls: map[int]time.Duration{404: time.Nanosecond}, expectedHits: 2},
+		{
+			name:         "5xx allowed",
+			rfc:          true,
+			status:       http.StatusServiceUnavailable,
+			ttls:         map[int]time.Duration{503: time.Minute},
+			expectedHits: 1,
+			negativeHits: 1,
+		},
+		{
+			name:         "uncachable by default",
+			rfc:          true,
+			status:       http.StatusForbidden,
+			ttls:         map[int]time.Duration{403: time.Minute},
+			expectedHits: 1,
+			negativeHits: 1,
+		},
+		{name: "5xx with rfc", rfc: true, status: http.StatusNotImplemented, expectedHits: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt64(&hits, 0)
+			stats := &httpcache.Stats{}
+			client := &http.Client{}
+			_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+				httpcache.WithRFCCompliance(tc.rfc),
+				httpcache.WithNegativeCaching(tc.ttls),
+				httpcache.WithObserver(stats),
+			)
+			require.NoError(t, err)
+
+			for i := 0; i < 2; i++ {
+				resp, _ := doGet(t, client, upstream.URL+"/"+strconv.Itoa(tc.status))
+				require.Equal(t, tc.status, resp.StatusCode)
+			}
+			require.Equal(t, tc.expectedHits, atomic.LoadInt64(&hits))
+			require.Equal(t, tc.negativeHits, stats.Count(httpcache.EventCacheNegativeHit))
+			// the negative hits are not counted as hits
+			require.EqualValues(t, 2-tc.expectedHits-int64(tc.negativeHits), stats.Count(httpcache.EventCacheHit))
+		})
+	}
+
+	_, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithNegativeCaching(map[int]time.Duration{http.StatusOK: time.Minute}))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+}
+
+func TestNegativeCachingDefaultTTL(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithRFCCompliance(false))
+	require.NoError(t, err)
+
+	doGet(t, client, upstream.URL+"/missing")
+	entry, err := handler.Lookup(httptestRequest(t, upstream.URL+"/missing"))
+	require.NoError(t, err)
+	entry.Response.Body.Close()
+	require.WithinDuration(t, time.Now().Add(httpcache.DefaultNegativeTTL), entry.ExpiresAt, time.Second)
+}
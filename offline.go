@@ -0,0 +1,82 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+)
+
+// SetOffline will toggle the offline mode at runtime. While offline, the upstream is never contacted:
+// any stored response is served whatever its staleness with the Warning 112 (Disconnected Operation),
+// and a 504 Gateway Timeout is synthesized when the response is not stored.
+func (r *CacheHandler) SetOffline(offline bool) {
+	r.offline.Store(offline)
+}
+
+// Offline will return whether the handler is offline, see SetOffline
+func (r *CacheHandler) Offline() bool {
+	return r.offline.Load()
+}
+
+// roundTripOffline will serve the request from the cache storage only
+func (r *CacheHandler) roundTripOffline(req *http.Request) (*http.Response, error) {
+	var err error
+	switch {
+	case r.graphQL != nil && r.graphQL.match(req):
+		if req, _, _, err = r.graphQL.parse(req); err != nil {
+			return nil, err
+		}
+	case (r.bodyKey != nil && req.Method == http.MethodPost) || r.replaying():
+		if req, _, err = r.hashRequestBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	key := r.CacheKey(req)
+	resp, item, err := r.readStoredResponse(req, key)
+	if err != nil {
+		r.emit(Event{Type: EventCacheMiss, Key: key, Request: req, Err: err})
+		return synthesizedResponse(req, http.StatusGatewayTimeout, cacheControl.WarningDisconnectedOperation,
+			"the handler is offline and the response is not stored"), nil
+	}
+	buildTheCachedResponseHeader(resp, item, r.CacheInteractor.Origin())
+	resp.Header.Add(HeaderWarning, cacheControl.WarningDisconnectedOperation.HeaderString("", time.Now()))
+	eventType := EventCacheHit
+	if resp.StatusCode >= http.StatusBadRequest {
+		eventType = EventCacheNegativeHit
+	}
+	r.emit(Event{Type: eventType, Key: key, Request: req})
+	return serveRange(req, resp)
+}
+
+// synthesizedResponse will build a response of the handler itself, i.e not sent by the upstream
+func synthesizedResponse(req *http.Request, status int, warning cacheControl.Warning, message string) *http.Response {
+	body := message + "\n"
+	header := http.Header{}
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	header.Set(HeaderCacheControl, "no-store")
+	header.Set(HeaderWarning, warning.HeaderString("", time.Now()))
+	header.Set(XFromHache, "true")
+	if req.Method == http.MethodHead {
+		// the Content-Length header is still the length of the GET response
+		body = ""
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
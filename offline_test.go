@@ -0,0 +1,70 @@
+package httpcache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffline(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		if r.URL.Path == "/stale" {
+			w.Header().Set("Cache-Control", "max-age=0")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}))
+	require.NoError(t, err)
+	doGet(t, client, upstream.URL+"/fresh")
+	doGet(t, client, upstream.URL+"/stale")
+	require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+
+	handler.SetOffline(true)
+	require.True(t, handler.Offline())
+	for _, path := range []string{"/fresh", "/stale"} {
+		resp, body := doGet(t, client, upstream.URL+path)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, path, body)
+		require.True(t, strings.HasPrefix(resp.Header.Get(httpcache.HeaderWarning), "112 "))
+	}
+	resp, body := doGet(t, client, upstream.URL+"/missing")
+	require.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	require.NotEmpty(t, body)
+	require.True(t, strings.HasPrefix(resp.Header.Get(httpcache.HeaderWarning), "112 "))
+
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodHead, upstream.URL+"/fresh", http.NoBody)
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+
+	// the synthesized HEAD response has no body
+	req, err = http.NewRequestWithContext(context.TODO(), http.MethodHead, upstream.URL+"/missing", http.NoBody)
+	require.NoError(t, err)
+	resp, err = handler.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	require.Zero(t, resp.ContentLength)
+	headBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Empty(t, headBody)
+
+	handler.SetOffline(false)
+	_, body = doGet(t, client, upstream.URL+"/stale")
+	require.Equal(t, "/stale", body)
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+}
@@ -0,0 +1,113 @@
+package httpcache
+
+import (
+	"net/http"
+
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+)
+
+// Option is the functional option to configure the CacheHandler, used by New
+type Option func(h *CacheHandler)
+
+// CacheMode represent whether the cache is shared by many users or private to a single user agent
+type CacheMode int
+
+// Cache modes
+const (
+	// SharedCache is the default mode, e.g a proxy or a client used on behalf of many users.
+	// The s-maxage directive is honored, the private responses are never stored, and the responses
+	// to authenticated requests are only stored when explicitly allowed (RFC 7234 section 3.2).
+	SharedCache CacheMode = iota
+	// PrivateCache is the mode for a cache dedicated to a single user agent. The private responses
+	// are stored, the s-maxage directive is ignored, and the responses to authenticated requests
+	// are partitioned by the Authorization header, see WithPartition.
+	PrivateCache
+)
+
+// String will return the string version of the cache mode
+func (m CacheMode) String() string {
+	switch m {
+	case SharedCache:
+		return "shared"
+	case PrivateCache:
+		return "private"
+	}
+	return "unknown"
+}
+
+// KeyFunc returns the key used to store the response of the request in the cache storage
+type KeyFunc func(req *http.Request) string
+
+// WithRFCCompliance will enable/disable the RFC 7234 compliance, it's enabled by default.
+// The downside of disabling it, all the responses will be cached.
+func WithRFCCompliance(val bool) Option {
+	return func(h *CacheHandler) {
+		h.ComplyRFC = val
+	}
+}
+
+// WithCacheMode will set whether the cache is shared or private, it's SharedCache by default
+func WithCacheMode(mode CacheMode) Option {
+	return func(h *CacheHandler) {
+		h.mode = mode
+	}
+}
+
+// WithTransport will set the http.RoundTripper used to send the requests that can't be served from the cache.
+// By default it's the Transport of the client, or the http.DefaultTransport if not set.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(h *CacheHandler) {
+		h.DefaultRoundTripper = transport
+	}
+}
+
+// WithObserver will add an Observer receiving the events of the CacheHandler.
+// It can be set multiple times, the events are sent to each observer in order.
+func WithObserver(observer Observer) Option {
+	return func(h *CacheHandler) {
+		switch o := h.Observer.(type) {
+		case nil:
+			h.Observer = observer
+		case multiObserver:
+			h.Observer = append(o, observer)
+		default:
+			h.Observer = multiObserver{o, observer}
+		}
+	}
+}
+
+// WithKeyFunc will override how the key of a request is built. The partition (see WithPartition) is still
+// added to the custom key, the body hash (see WithBodyKey) is not. Note the admin API relies on the default key, which is started
+// with the request method and URL, to purge by prefix.
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(h *CacheHandler) {
+		h.keyFunc = fn
+	}
+}
+
+// WithMaxResponseSize will skip storing the responses larger than the size in bytes.
+// Zero means no limit, which is the default.
+func WithMaxResponseSize(size int64) Option {
+	return func(h *CacheHandler) {
+		h.maxResponseSize = size
+	}
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) OnEvent(ev Event) {
+	for _, o := range m {
+		o.OnEvent(ev)
+	}
+}
+
+// WithIgnoredReasons will store the responses regardless the given reasons to not to cache, so the handler
+// stays RFC 7234 compliant except for the deliberately chosen deviations. Note the key doesn't include
+// the request body nor the credentials, e.g combine the ReasonRequestAuthorizationHeader with WithPartition.
+// The method reasons can't be ignored, the unsafe requests are never served from the cache storage, see
+// WithBodyKey for caching the POST requests.
+func WithIgnoredReasons(reasons ...cacheControl.Reason) Option {
+	return func(h *CacheHandler) {
+		h.ignoredReasons = append(h.ignoredReasons, reasons...)
+	}
+}
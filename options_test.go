@@ -0,0 +1,160 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+	"github.com/stretchr/testify/require"
+)
+
+func newUpstream(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func doGet(t *testing.T, client *http.Client, target string) (*http.Response, string) {
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, target, http.NoBody)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestNewWithOptions(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, strings.Repeat("x", len(r.URL.Path)))
+	})
+
+	stats, otherStats := &httpcache.Stats{}, &httpcache.Stats{}
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithObserver(stats),
+		httpcache.WithObserver(otherStats),
+		httpcache.WithMaxResponseSize(200),
+		// ignore the query
+		httpcache.WithKeyFunc(func(req *http.Request) string {
+			return req.Method + " " + req.URL.Path
+		}),
+	)
+	require.NoError(t, err)
+	require.True(t, handler.ComplyRFC)
+	require.Equal(t, "GET /a", handler.CacheKey(httptestRequest(t, upstream.URL+"/a?q=1")))
+
+	doGet(t, client, upstream.URL+"/a?q=1")
+	resp, _ := doGet(t, client, upstream.URL+"/a?q=2")
+	require.Equal(t, "true", resp.Header.Get(httpcache.XFromHache))
+
+	// larger than the max response size
+	long := "/" + strings.Repeat("b", 300)
+	doGet(t, client, upstream.URL+long)
+	resp, body := doGet(t, client, upstream.URL+long)
+	require.Empty(t, resp.Header.Get(httpcache.XFromHache))
+	require.Len(t, body, len(long))
+
+	for _, s := range []*httpcache.Stats{stats, otherStats} {
+		require.EqualValues(t, 1, s.Count(httpcache.EventCacheHit))
+		require.EqualValues(t, 1, s.Count(httpcache.EventCacheStored))
+		require.EqualValues(t, 2, s.Count(httpcache.EventCacheSkipped))
+	}
+}
+
+func TestWithIgnoredReasons(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.Header.Get("Authorization"))
+	})
+	get := func(client *http.Client, auth string) string {
+		req := httptestRequest(t, upstream.URL)
+		req.Header.Set("Authorization", auth)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		opts         []httpcache.Option
+		expectedHits int64
+	}{
+		{name: "rfc compliant", expectedHits: 3},
+		{
+			name: "ignore authorization",
+			opts: []httpcache.Option{
+				httpcache.WithIgnoredReasons(cacheControl.ReasonRequestAuthorizationHeader),
+				httpcache.WithPartition(httpcache.PartitionByAuthorization()),
+			},
+			expectedHits: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt64(&hits, 0)
+			client := &http.Client{}
+			_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}), tc.opts...)
+			require.NoError(t, err)
+			for _, auth := range []string{"alice", "alice", "bob"} {
+				require.Equal(t, auth, get(client, auth))
+			}
+			require.Equal(t, tc.expectedHits, atomic.LoadInt64(&hits))
+		})
+	}
+
+	_, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithIgnoredReasons(cacheControl.Reason(100)))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+
+	for _, reason := range []cacheControl.Reason{cacheControl.ReasonRequestMethodPOST, cacheControl.ReasonRequestMethodDELETE} {
+		_, err = httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+			httpcache.WithIgnoredReasons(reason))
+		require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+	}
+}
+
+func TestUnsafeMethodsNeverCached(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.Method)
+	})
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithRFCCompliance(false))
+	require.NoError(t, err)
+
+	for _, method := range []string{http.MethodDelete, http.MethodDelete, http.MethodPost, http.MethodPost} {
+		req, err := http.NewRequestWithContext(context.TODO(), method, upstream.URL+"/a", http.NoBody)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Empty(t, resp.Header.Get(httpcache.XFromHache))
+	}
+	require.EqualValues(t, 4, atomic.LoadInt64(&hits))
+	_, err = handler.Lookup(httptestRequest(t, upstream.URL+"/a"))
+	require.Error(t, err)
+}
+
+func httptestRequest(t *testing.T, target string) *http.Request {
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, target, http.NoBody)
+	require.NoError(t, err)
+	return req
+}
@@ -0,0 +1,96 @@
+package httpcache
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// partitionSecretSize is the size of the random partition secret, see WithPartitionSecret
+const partitionSecretSize = 32
+
+// PartitionFunc derives the partition ID of the request, e.g the user or the tenant of the request.
+// The stored responses are never served across partitions. An empty ID means the request is not partitioned.
+//
+// The ID is hashed with an HMAC before it's put in the key, so the raw credentials are never stored in the
+// cache storage, and they can't be brute-forced from the keys without the secret, see WithPartitionSecret.
+type PartitionFunc func(req *http.Request) string
+
+// PartitionByAuthorization partitions the requests by the Authorization header
+func PartitionByAuthorization() PartitionFunc {
+	return PartitionByHeader(HeaderAuthorization)
+}
+
+// PartitionByHeader partitions the requests by the value of the header, e.g a tenant header
+func PartitionByHeader(name string) PartitionFunc {
+	return func(req *http.Request) string {
+		return req.Header.Get(name)
+	}
+}
+
+// PartitionByCookie partitions the requests by the value of the cookie, e.g a session cookie
+func PartitionByCookie(name string) PartitionFunc {
+	return func(req *http.Request) string {
+		cookie, err := req.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// WithPartition will partition the stored responses by the ID derived from the request.
+// By default the PrivateCache is partitioned by the Authorization header, the SharedCache is not partitioned.
+func WithPartition(fn PartitionFunc) Option {
+	return func(h *CacheHandler) {
+		h.partitionFunc = fn
+	}
+}
+
+// WithPartitionSecret will set the secret of the HMAC hashing the partition IDs. By default it's a random
+// secret generated when the handler is created, so the partitioned responses stored by another handler, or
+// before a restart, are never found. Set the same secret to the handlers sharing the cache storage, it's
+// required to replay the partitioned responses, see WithReplay.
+func WithPartitionSecret(secret []byte) Option {
+	return func(h *CacheHandler) {
+		h.partitionSecret = secret
+	}
+}
+
+// initPartitionSecret will generate a random partition secret, unless it's set by WithPartitionSecret
+func (r *CacheHandler) initPartitionSecret() error {
+	if r.partitionSecret != nil {
+		return nil
+	}
+	r.partitionSecret = make([]byte, partitionSecretSize)
+	if _, err := rand.Read(r.partitionSecret); err != nil {
+		return fmt.Errorf("can't generate the partition secret: %w", err)
+	}
+	return nil
+}
+
+// partitioner will return the PartitionFunc of the handler, nil if the stored responses aren't partitioned
+func (r *CacheHandler) partitioner() PartitionFunc {
+	if r.partitionFunc == nil && r.mode == PrivateCache {
+		return PartitionByAuthorization()
+	}
+	return r.partitionFunc
+}
+
+// partition will return the hashed partition ID of the request, or empty if the request is not partitioned
+func (r *CacheHandler) partition(req *http.Request) string {
+	fn := r.partitioner()
+	if fn == nil {
+		return ""
+	}
+	id := fn(req)
+	if id == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, r.partitionSecret)
+	mac.Write([]byte(id)) //nolint
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,135 @@
+package httpcache_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartition(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.Header.Get("X-Tenant"))
+	})
+
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithPartition(httpcache.PartitionByHeader("X-Tenant")))
+	require.NoError(t, err)
+
+	get := func(tenant string) string {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, upstream.URL+"/items", http.NoBody)
+		require.NoError(t, err)
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	for _, tenant := range []string{"acme", "acme", "globex", "", "globex", ""} {
+		require.Equal(t, tenant, get(tenant))
+	}
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+	req := httptestRequest(t, upstream.URL+"/items")
+	require.Equal(t, "GET "+upstream.URL+"/items", handler.CacheKey(req))
+	req.Header.Set("X-Tenant", "acme")
+	require.True(t, strings.HasPrefix(handler.CacheKey(req), "GET "+upstream.URL+"/items partition="))
+	require.NotContains(t, handler.CacheKey(req), "acme")
+}
+
+func TestPartitionByAuthorizationInPrivateCache(t *testing.T) {
+	handler, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithCacheMode(httpcache.PrivateCache))
+	require.NoError(t, err)
+
+	alice := httptestRequest(t, "http://example.com/me")
+	alice.Header.Set("Authorization", "Bearer alice-token")
+	bob := httptestRequest(t, "http://example.com/me")
+	bob.Header.Set("Authorization", "Bearer bob-token")
+
+	// the raw credentials are never put in the key
+	require.NotContains(t, handler.CacheKey(alice), "alice-token")
+	require.NotEqual(t, handler.CacheKey(alice), handler.CacheKey(bob))
+	require.Equal(t, handler.CacheKey(alice), handler.CacheKey(alice.Clone(context.TODO())))
+}
+
+func TestPartitionSecret(t *testing.T) {
+	newHandler := func(opts ...httpcache.Option) *httpcache.CacheHandler {
+		opts = append(opts, httpcache.WithPartition(httpcache.PartitionByAuthorization()))
+		handler, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}), opts...)
+		require.NoError(t, err)
+		return handler
+	}
+	req := httptestRequest(t, "http://example.com/me")
+	req.Header.Set("Authorization", "Basic YWxpY2U6c2VjcmV0")
+
+	// the partition can't be derived without the secret
+	sum := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+	require.NotContains(t, newHandler().CacheKey(req), hex.EncodeToString(sum[:]))
+	require.NotEqual(t, newHandler().CacheKey(req), newHandler().CacheKey(req))
+
+	secret := httpcache.WithPartitionSecret([]byte("shared-secret"))
+	require.Equal(t, newHandler(secret).CacheKey(req), newHandler(secret).CacheKey(req))
+
+	_, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithPartitionSecret([]byte{}))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+}
+
+func TestPartitionByCookie(t *testing.T) {
+	fn := httpcache.PartitionByCookie("session")
+	req := httptestRequest(t, "http://example.com/")
+	require.Empty(t, fn(req))
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	require.Equal(t, "s1", fn(req))
+}
+
+func TestPartitionWithKeyFunc(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		_, _ = io.WriteString(w, r.Header.Get("Authorization"))
+	})
+	client := &http.Client{}
+	_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithCacheMode(httpcache.PrivateCache),
+		httpcache.WithKeyFunc(func(req *http.Request) string {
+			return req.Method + " " + req.URL.Path
+		}))
+	require.NoError(t, err)
+
+	get := func(authorization string) string {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, upstream.URL+"/me?q=1", http.NoBody)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", authorization)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	for _, authorization := range []string{"Bearer alice", "Bearer alice", "Bearer bob", "Bearer bob"} {
+		require.Equal(t, authorization, get(authorization))
+	}
+	require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+}
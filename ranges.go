@@ -0,0 +1,197 @@
+package httpcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Range headers: https://tools.ietf.org/html/rfc7233
+const (
+	HeaderRange        = "Range"
+	HeaderIfRange      = "If-Range"
+	HeaderContentRange = "Content-Range"
+)
+
+var (
+	errInvalidRange = errors.New("invalid range")
+	errNoOverlap    = errors.New("invalid range: failed to overlap")
+)
+
+// httpRange is a byte range of the representation, the length is the number of bytes
+type httpRange struct {
+	start, length int64
+}
+
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// serveRange will serve the byte ranges of the request from the stored full response, as a single
+// or a multipart/byteranges 206 Partial Content. The full response is served when the request has no
+// valid range, or when the If-Range doesn't match the stored response.
+func serveRange(req *http.Request, resp *http.Response) (*http.Response, error) {
+	rangeHeader := req.Header.Get(HeaderRange)
+	if rangeHeader == "" || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	if ifRange := req.Header.Get(HeaderIfRange); ifRange != "" && !ifRangeMatch(ifRange, resp.Header) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	size := int64(len(body))
+
+	ranges, err := parseRange(rangeHeader, size)
+	switch {
+	case errors.Is(err, errNoOverlap):
+		partial := partialResponse(resp, http.StatusRequestedRangeNotSatisfiable, nil)
+		partial.Header.Set(HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+		return partial, nil
+	case err != nil, sumRangesSize(ranges) > size:
+		// the invalid range is ignored, and the ranges larger than the representation are not worth it
+		return resp, nil
+	case len(ranges) == 1:
+		ra := ranges[0]
+		partial := partialResponse(resp, http.StatusPartialContent, body[ra.start:ra.start+ra.length])
+		partial.Header.Set(HeaderContentRange, ra.contentRange(size))
+		return partial, nil
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, ra := range ranges {
+		partHeader := textproto.MIMEHeader{}
+		if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+			partHeader.Set("Content-Type", contentType)
+		}
+		partHeader.Set(HeaderContentRange, ra.contentRange(size))
+		part, err := mw.CreatePart(partHeader)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = part.Write(body[ra.start : ra.start+ra.length]); err != nil {
+			return nil, err
+		}
+	}
+	if err = mw.Close(); err != nil {
+		return nil, err
+	}
+	partial := partialResponse(resp, http.StatusPartialContent, buf.Bytes())
+	partial.Header.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	return partial, nil
+}
+
+// partialResponse will build the response of the range request from the stored full response
+func partialResponse(resp *http.Response, status int, body []byte) *http.Response {
+	partial := new(http.Response)
+	*partial = *resp
+	partial.StatusCode = status
+	partial.Status = fmt.Sprintf("%d %s", status, http.StatusText(status))
+	partial.Header = resp.Header.Clone()
+	partial.ContentLength = int64(len(body))
+	partial.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	partial.Body = io.NopCloser(bytes.NewReader(body))
+	return partial
+}
+
+// ifRangeMatch will check the If-Range against the stored response validators:
+// https://tools.ietf.org/html/rfc7233#section-3.2
+func ifRangeMatch(ifRange string, header http.Header) bool {
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		// the strong comparison, the weak entity tags never match
+		etag := header.Get("ETag")
+		return !strings.HasPrefix(ifRange, "W/") && !strings.HasPrefix(etag, "W/") && etag == ifRange
+	}
+	return header.Get("Last-Modified") == ifRange
+}
+
+// parseRange will parse the Range header against the representation size, adapted from the net/http.
+// The errNoOverlap is returned if none of the ranges overlap the representation.
+func parseRange(s string, size int64) ([]httpRange, error) {
+	const b = "bytes="
+	if !strings.HasPrefix(s, b) {
+		return nil, errInvalidRange
+	}
+	var ranges []httpRange
+	noOverlap := false
+	for _, ra := range strings.Split(s[len(b):], ",") {
+		ra = textproto.TrimString(ra)
+		if ra == "" {
+			continue
+		}
+		i := strings.Index(ra, "-")
+		if i < 0 {
+			return nil, errInvalidRange
+		}
+		start, end := textproto.TrimString(ra[:i]), textproto.TrimString(ra[i+1:])
+		var r httpRange
+		if start == "" {
+			// the suffix range, e.g "-500" is the final 500 bytes
+			if end == "" || end[0] == '-' {
+				return nil, errInvalidRange
+			}
+			n, err := strconv.ParseInt(end, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errInvalidRange
+			}
+			if n == 0 || size == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r.start = size - n
+			r.length = size - r.start
+		} else {
+			n, err := strconv.ParseInt(start, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errInvalidRange
+			}
+			if n >= size {
+				noOverlap = true
+				continue
+			}
+			r.start = n
+			if end == "" {
+				r.length = size - r.start
+			} else {
+				n, err := strconv.ParseInt(end, 10, 64)
+				if err != nil || r.start > n {
+					return nil, errInvalidRange
+				}
+				if n >= size {
+					n = size - 1
+				}
+				r.length = n - r.start + 1
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, errNoOverlap
+		}
+		return nil, errInvalidRange
+	}
+	return ranges, nil
+}
+
+func sumRangesSize(ranges []httpRange) (size int64) {
+	for _, ra := range ranges {
+		size += ra.length
+	}
+	return
+}
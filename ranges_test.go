@@ -0,0 +1,105 @@
+package httpcache_test
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeRequests(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/plain")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	})
+
+	client := &http.Client{}
+	_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}))
+	require.NoError(t, err)
+
+	get := func(rangeHeader, ifRange string) (*http.Response, string) {
+		req := httptestRequest(t, upstream.URL)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		if ifRange != "" {
+			req.Header.Set("If-Range", ifRange)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	// the partial response from the upstream is never stored
+	resp, body := get("bytes=0-3", "")
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "0123", body)
+	get("", "")
+	require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+
+	for _, tc := range []struct {
+		name                 string
+		rangeHeader, ifRange string
+		expectedStatus       int
+		expectedBody         string
+		expectedContentRange string
+	}{
+		{name: "full", expectedStatus: http.StatusOK, expectedBody: "0123456789"},
+		{name: "single", rangeHeader: "bytes=2-4", expectedStatus: http.StatusPartialContent, expectedBody: "234", expectedContentRange: "bytes 2-4/10"},
+		{name: "suffix", rangeHeader: "bytes=-3", expectedStatus: http.StatusPartialContent, expectedBody: "789", expectedContentRange: "bytes 7-9/10"},
+		{name: "open ended", rangeHeader: "bytes=8-", expectedStatus: http.StatusPartialContent, expectedBody: "89", expectedContentRange: "bytes 8-9/10"},
+		{name: "if-range match", rangeHeader: "bytes=0-0", ifRange: `"v1"`, expectedStatus: http.StatusPartialContent, expectedBody: "0", expectedContentRange: "bytes 0-0/10"},
+		{name: "if-range mismatch", rangeHeader: "bytes=0-0", ifRange: `"v0"`, expectedStatus: http.StatusOK, expectedBody: "0123456789"},
+		{name: "if-range weak", rangeHeader: "bytes=0-0", ifRange: `W/"v1"`, expectedStatus: http.StatusOK, expectedBody: "0123456789"},
+		{name: "unsatisfiable", rangeHeader: "bytes=20-", expectedStatus: http.StatusRequestedRangeNotSatisfiable, expectedContentRange: "bytes */10"},
+		{name: "invalid", rangeHeader: "items=0-1", expectedStatus: http.StatusOK, expectedBody: "0123456789"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := get(tc.rangeHeader, tc.ifRange)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode)
+			require.Equal(t, tc.expectedBody, body)
+			require.Equal(t, tc.expectedContentRange, resp.Header.Get(httpcache.HeaderContentRange))
+			require.Equal(t, "true", resp.Header.Get(httpcache.XFromHache))
+		})
+	}
+
+	t.Run("multipart", func(t *testing.T) {
+		resp, body := get("bytes=0-1,5-6", "")
+		require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/byteranges", mediaType)
+
+		reader := multipart.NewReader(strings.NewReader(body), params["boundary"])
+		for _, expected := range []struct{ body, contentRange string }{
+			{body: "01", contentRange: "bytes 0-1/10"},
+			{body: "56", contentRange: "bytes 5-6/10"},
+		} {
+			part, err := reader.NextPart()
+			require.NoError(t, err)
+			require.Equal(t, expected.contentRange, part.Header.Get(httpcache.HeaderContentRange))
+			require.Equal(t, "text/plain", part.Header.Get("Content-Type"))
+			partBody, err := io.ReadAll(part)
+			require.NoError(t, err)
+			require.Equal(t, expected.body, string(partBody))
+		}
+		_, err = reader.NextPart()
+		require.Equal(t, io.EOF, err)
+	})
+	require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+}
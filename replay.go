@@ -0,0 +1,127 @@
+package httpcache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotRecorded will throw in the ReplayOnly mode if the response of the request is not recorded
+var ErrNotRecorded = errors.New("response is not recorded")
+
+// ReplayMode represent how the requests are served for the deterministic tests, VCR-style, see WithReplay
+type ReplayMode int
+
+// Replay modes
+const (
+	// ReplayDisabled is the default mode, the responses are cached according to the RFC 7234 (when complied)
+	ReplayDisabled ReplayMode = iota
+	// ReplayRecord sends every request to the upstream, and stores every response regardless of its headers
+	ReplayRecord
+	// ReplayOnly serves every request from the recorded responses regardless of their freshness,
+	// the request never reaches the upstream and fails with the ErrNotRecorded if it's not recorded
+	ReplayOnly
+	// ReplayPassthrough sends every request to the upstream, nothing is served from nor stored to the cache storage
+	ReplayPassthrough
+)
+
+// String will return the string version of the replay mode
+func (m ReplayMode) String() string {
+	switch m {
+	case ReplayDisabled:
+		return "disabled"
+	case ReplayRecord:
+		return "record"
+	case ReplayOnly:
+		return "replay"
+	case ReplayPassthrough:
+		return "passthrough"
+	}
+	return "unknown"
+}
+
+// ParseReplayMode will parse the string version of the replay mode, e.g from an environment variable.
+// The empty string is the ReplayDisabled.
+func ParseReplayMode(s string) (ReplayMode, error) {
+	if s == "" {
+		return ReplayDisabled, nil
+	}
+	for m := ReplayDisabled; m <= ReplayPassthrough; m++ {
+		if strings.EqualFold(s, m.String()) {
+			return m, nil
+		}
+	}
+	return ReplayDisabled, fmt.Errorf("%w: unknown replay mode %q", ErrInvalidOption, s)
+}
+
+// WithReplay will set the replay mode, for the tests hitting third-party APIs. The recorded responses
+// are stored to the cache storage in the same format as the cached responses, e.g a disk.NewCache
+// without expiry is a cassette to be committed along with the tests. The request body is part of the key.
+// The partitioned responses, e.g in the PrivateCache mode, are replayed with the same WithPartitionSecret only.
+func WithReplay(mode ReplayMode) Option {
+	return func(h *CacheHandler) {
+		h.replay = mode
+	}
+}
+
+// roundTripReplay will serve the request according to the replay mode
+func (r *CacheHandler) roundTripReplay(req *http.Request) (resp *http.Response, err error) {
+	if r.replay == ReplayPassthrough {
+		return r.DefaultRoundTripper.RoundTrip(req)
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		var ok bool
+		if req, ok, err = r.hashRequestBody(req); err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s %s", ErrRequestTooLarge, req.Method, req.URL)
+		}
+	}
+
+	key := r.replayKey(req)
+	if r.replay == ReplayOnly {
+		resp, item, err := r.readStoredResponse(req, key)
+		if err != nil && req.Method == http.MethodHead {
+			// the HEAD request not recorded is replayed from the recorded GET response, without its body
+			key = r.CacheKey(req)
+			resp, item, err = r.readStoredResponse(req, key)
+		}
+		if err != nil {
+			r.emit(Event{Type: EventCacheMiss, Key: key, Request: req, Err: err})
+			return nil, fmt.Errorf("%w: %s %s (key %q): %v", ErrNotRecorded, req.Method, req.URL, key, err)
+		}
+		buildTheCachedResponseHeader(resp, item, r.CacheInteractor.Origin())
+		r.emit(Event{Type: EventCacheHit, Key: key, Request: req})
+		return resp, nil
+	}
+
+	resp, err = r.DefaultRoundTripper.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if err = storeRespToCache(r.CacheInteractor, req, resp, key, time.Time{}, 0); err != nil {
+		resp.Body.Close()
+		r.emit(Event{Type: EventStorageError, Key: key, Request: req, Err: err})
+		return nil, fmt.Errorf("can't record the response of %s %s: %w", req.Method, req.URL, err)
+	}
+	r.emit(Event{Type: EventCacheStored, Key: key, Request: req})
+	return resp, nil
+}
+
+// replayKey will return the key of the recorded response, the HEAD response is recorded apart from the
+// GET response, which is the key of the HEAD request otherwise, see CacheKey
+func (r *CacheHandler) replayKey(req *http.Request) string {
+	key := r.CacheKey(req)
+	if req.Method == http.MethodHead {
+		key += " method=HEAD"
+	}
+	return key
+}
+
+// replaying will check whether the request body is part of the key because of the replay mode
+func (r *CacheHandler) replaying() bool {
+	return r.replay == ReplayRecord || r.replay == ReplayOnly
+}
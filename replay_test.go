@@ -0,0 +1,161 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/disk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay(t *testing.T) {
+	var hits int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		// recorded regardless of the headers and the status
+		w.Header().Set("Cache-Control", "no-store")
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, r.Method+" "+r.URL.Path+" "+string(body))
+	}))
+	defer upstream.Close()
+	cassette := t.TempDir()
+
+	do := func(client *http.Client, method, path, body string) (int, string, error) {
+		req, err := http.NewRequestWithContext(context.TODO(), method, upstream.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(respBody), nil
+	}
+	newClient := func(mode httpcache.ReplayMode) *http.Client {
+		client := &http.Client{}
+		_, err := httpcache.New(client, disk.NewCache(cassette, 0), httpcache.WithReplay(mode))
+		require.NoError(t, err)
+		return client
+	}
+
+	client := newClient(httpcache.ReplayRecord)
+	for _, path := range []string{"/products", "/error"} {
+		_, _, err := do(client, http.MethodGet, path, "")
+		require.NoError(t, err)
+	}
+	_, _, err := do(client, http.MethodPost, "/search", `{"q":"a"}`)
+	require.NoError(t, err)
+	_, _, err = do(client, http.MethodPost, "/search", `{"q":"b"}`)
+	require.NoError(t, err)
+	_, _, err = do(client, http.MethodHead, "/head", "")
+	require.NoError(t, err)
+	require.EqualValues(t, 5, atomic.LoadInt64(&hits))
+
+	client = newClient(httpcache.ReplayOnly)
+	status, body, err := do(client, http.MethodGet, "/error", "")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Equal(t, "GET /error ", body)
+	_, body, err = do(client, http.MethodPost, "/search", `{"q":"b"}`)
+	require.NoError(t, err)
+	require.Equal(t, `POST /search {"q":"b"}`, body)
+	_, _, err = do(client, http.MethodPost, "/search", `{"q":"c"}`)
+	require.True(t, errors.Is(err, httpcache.ErrNotRecorded), err)
+	require.Contains(t, err.Error(), "POST "+upstream.URL+"/search")
+	// the HEAD requests are replayed from the recorded HEAD response, or the recorded GET response
+	for _, path := range []string{"/head", "/products"} {
+		status, body, err = do(client, http.MethodHead, path, "")
+		require.NoError(t, err, path)
+		require.Equal(t, http.StatusOK, status)
+		require.Empty(t, body)
+	}
+	_, _, err = do(client, http.MethodGet, "/head", "")
+	require.True(t, errors.Is(err, httpcache.ErrNotRecorded), err)
+	require.EqualValues(t, 5, atomic.LoadInt64(&hits))
+
+	client = newClient(httpcache.ReplayPassthrough)
+	_, body, err = do(client, http.MethodGet, "/passthrough", "")
+	require.NoError(t, err)
+	require.Equal(t, "GET /passthrough ", body)
+	require.EqualValues(t, 6, atomic.LoadInt64(&hits))
+	_, _, err = do(newClient(httpcache.ReplayOnly), http.MethodGet, "/passthrough", "")
+	require.True(t, errors.Is(err, httpcache.ErrNotRecorded), err)
+}
+
+func TestParseReplayMode(t *testing.T) {
+	for s, expected := range map[string]httpcache.ReplayMode{
+		"":            httpcache.ReplayDisabled,
+		"record":      httpcache.ReplayRecord,
+		"REPLAY":      httpcache.ReplayOnly,
+		"passthrough": httpcache.ReplayPassthrough,
+	} {
+		mode, err := httpcache.ParseReplayMode(s)
+		require.NoError(t, err)
+		require.Equal(t, expected, mode)
+	}
+	_, err := httpcache.ParseReplayMode("rewind")
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+}
+
+func TestReplayPartitioned(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		_, _ = io.WriteString(w, r.Header.Get("Authorization"))
+	})
+	cassette := t.TempDir()
+	newClient := func(mode httpcache.ReplayMode) *http.Client {
+		client := &http.Client{}
+		_, err := httpcache.New(client, disk.NewCache(cassette, 0), httpcache.WithReplay(mode),
+			httpcache.WithCacheMode(httpcache.PrivateCache),
+			httpcache.WithPartitionSecret([]byte("cassette-secret")))
+		require.NoError(t, err)
+		return client
+	}
+	do := func(client *http.Client, authorization string) (string, error) {
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, upstream.URL+"/me", http.NoBody)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", authorization)
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body), nil
+	}
+
+	recorder := newClient(httpcache.ReplayRecord)
+	for _, authorization := range []string{"Bearer alice", "Bearer bob"} {
+		_, err := do(recorder, authorization)
+		require.NoError(t, err)
+	}
+
+	// replayed by another handler
+	player := newClient(httpcache.ReplayOnly)
+	for _, authorization := range []string{"Bearer alice", "Bearer bob"} {
+		body, err := do(player, authorization)
+		require.NoError(t, err)
+		require.Equal(t, authorization, body)
+	}
+	_, err := do(player, "Bearer carol")
+	require.True(t, errors.Is(err, httpcache.ErrNotRecorded), err)
+	require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+
+	// the random partition secret can't be replayed
+	_, err = httpcache.New(&http.Client{}, disk.NewCache(cassette, 0), httpcache.WithReplay(httpcache.ReplayOnly),
+		httpcache.WithPartition(httpcache.PartitionByHeader("X-Tenant")))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+}
@@ -3,21 +3,31 @@ package httpcache
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httputil"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bxcodec/httpcache/cache"
 	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
 )
 
+// pingTimeout is the max time waiting the cache storage to respond the ping when the handler is created
+const pingTimeout = 5 * time.Second
+
 // Headers
 const (
 	HeaderAuthorization = "Authorization"
 	HeaderCacheControl  = "Cache-Control"
+	HeaderAge           = "Age"
+	HeaderExpires       = "Expires"
 	// To indicate that the response is got from this httpcache library
 	XFromHache   = "X-HTTPCache"
 	XHacheOrigin = "X-HTTPCache-Origin"
@@ -28,21 +38,140 @@ type CacheHandler struct {
 	DefaultRoundTripper http.RoundTripper
 	CacheInteractor     cache.ICacheInteractor
 	ComplyRFC           bool
+	Observer            Observer // Optional, receives the events of the cache handler
+
+	mode            CacheMode
+	rules           []Rule
+	revalidating    sync.Map // The keys being revalidated in the background
+	partitionFunc   PartitionFunc
+	partitionSecret []byte // The secret of the HMAC hashing the partition IDs
+	keyFunc         KeyFunc
+	maxResponseSize int64
+	ignoredReasons  []cacheControl.Reason
+	heuristics      map[string]cacheControl.Heuristic // The heuristic per host, the empty host is the default
+	negativeTTLs    map[int]time.Duration
+	bodyKey         *BodyKeyOptions
+	graphQL         *graphQLHandler
+	breaker         *circuitBreaker
+	async           *asyncStore
+	health          *storageHealth
+	replay          ReplayMode
+	offline         atomic.Bool
 }
 
 // NewCacheHandlerRoundtrip will create an implementations of cache http roundtripper
-func NewCacheHandlerRoundtrip(defaultRoundTripper http.RoundTripper, rfcCompliance bool, cacheActor cache.ICacheInteractor) *CacheHandler {
-	if cacheActor == nil {
-		log.Fatal("cache storage is not well set")
-	}
-	return &CacheHandler{
+func NewCacheHandlerRoundtrip(defaultRoundTripper http.RoundTripper, rfcCompliance bool,
+	cacheActor cache.ICacheInteractor) (*CacheHandler, error) {
+	return newCacheHandler(defaultRoundTripper, cacheActor, WithRFCCompliance(rfcCompliance))
+}
+
+func newCacheHandler(defaultRoundTripper http.RoundTripper, cacheActor cache.ICacheInteractor,
+	opts ...Option) (*CacheHandler, error) {
+	handler := &CacheHandler{
 		DefaultRoundTripper: defaultRoundTripper,
 		CacheInteractor:     cacheActor,
-		ComplyRFC:           rfcCompliance,
+		ComplyRFC:           true,
+	}
+	for _, opt := range opts {
+		opt(handler)
 	}
+	if err := handler.validate(); err != nil {
+		return nil, err
+	}
+	if err := handler.initPartitionSecret(); err != nil {
+		return nil, err
+	}
+	if handler.async != nil {
+		handler.async.start(handler)
+	}
+	return handler, nil
 }
 
-func validateTheCacheControl(req *http.Request, resp *http.Response) (validationResult cacheControl.ObjectResults, err error) {
+// validate will check the handler configuration, including the reachability of the cache storage
+func (r *CacheHandler) validate() error {
+	if r.CacheInteractor == nil {
+		return ErrNilCacheInteractor
+	}
+	if r.DefaultRoundTripper == nil {
+		return ErrNilTransport
+	}
+	if r.mode != SharedCache && r.mode != PrivateCache {
+		return fmt.Errorf("%w: unknown cache mode %d", ErrInvalidOption, r.mode)
+	}
+	if r.maxResponseSize < 0 {
+		return fmt.Errorf("%w: the max response size must not be negative", ErrInvalidOption)
+	}
+	for i := range r.rules {
+		if err := r.rules[i].compile(); err != nil {
+			return err
+		}
+	}
+	for host, heuristic := range r.heuristics {
+		if err := validateHeuristic(heuristic); err != nil {
+			return fmt.Errorf("%w: host %q: %v", ErrInvalidOption, host, err)
+		}
+	}
+	if r.bodyKey != nil && r.bodyKey.MaxBodySize < 0 {
+		return fmt.Errorf("%w: the max body size must not be negative", ErrInvalidOption)
+	}
+	if r.partitionSecret != nil && len(r.partitionSecret) == 0 {
+		return fmt.Errorf("%w: the partition secret must not be empty", ErrInvalidOption)
+	}
+	if err := validateNegativeTTLs(r.negativeTTLs); err != nil {
+		return err
+	}
+	if r.replay < ReplayDisabled || r.replay > ReplayPassthrough {
+		return fmt.Errorf("%w: unknown replay mode %d", ErrInvalidOption, r.replay)
+	}
+	if r.replaying() && r.partitioner() != nil && r.partitionSecret == nil {
+		// the random secret changes with each handler, the recorded responses would never be replayed
+		return fmt.Errorf("%w: the partition secret must be set to replay the partitioned responses", ErrInvalidOption)
+	}
+	if r.breaker != nil {
+		if err := r.breaker.validate(); err != nil {
+			return err
+		}
+	}
+	if r.async != nil {
+		if err := r.async.validate(); err != nil {
+			return err
+		}
+	}
+	if r.health != nil {
+		if err := r.health.validate(); err != nil {
+			return err
+		}
+	}
+	if r.graphQL != nil {
+		if err := r.graphQL.validate(r.CacheInteractor); err != nil {
+			return err
+		}
+	}
+	for _, reason := range r.ignoredReasons {
+		if reason < cacheControl.ReasonRequestMethodPOST || reason > cacheControl.ReasonResponseUncachableByDefault {
+			return fmt.Errorf("%w: unknown reason %d", ErrInvalidOption, reason)
+		}
+		if reason <= cacheControl.ReasonRequestMethodUnkown {
+			// the unsafe requests would be served from the cache, and the POST requests would share a key
+			return fmt.Errorf("%w: the reason %s can't be ignored, see WithBodyKey for caching the POST requests",
+				ErrInvalidOption, reason)
+		}
+	}
+
+	if pinger, ok := r.CacheInteractor.(cache.Pinger); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		defer cancel()
+		if err := pinger.Ping(ctx); err != nil {
+			return fmt.Errorf("%w: %v", ErrStorageUnreachable, err)
+		}
+	}
+	return nil
+}
+
+// validateTheCacheControl will examine the request and response based on RFC 7234.
+// The now is the time the response received from the server, the expiration time is calculated from it.
+func (r *CacheHandler) validateTheCacheControl(req *http.Request, resp *http.Response,
+	now time.Time) (validationResult cacheControl.ObjectResults, err error) {
 	reqDir, err := cacheControl.ParseRequestCacheControl(req.Header.Get("Cache-Control"))
 	if err != nil {
 		return
@@ -74,6 +203,9 @@ func validateTheCacheControl(req *http.Request, resp *http.Response) (validation
 	}
 
 	obj := cacheControl.Object{
+		CacheIsPrivate: r.mode == PrivateCache,
+		Heuristic:      r.heuristic(req),
+
 		RespDirectives:         resDir,
 		RespHeaders:            resp.Header,
 		RespStatusCode:         resp.StatusCode,
@@ -83,7 +215,7 @@ func validateTheCacheControl(req *http.Request, resp *http.Response) (validation
 		ReqDirectives:          reqDir,
 		ReqHeaders:             req.Header,
 		ReqMethod:              req.Method,
-		NowUTC:                 time.Now().UTC(),
+		NowUTC:                 now.UTC(),
 	}
 
 	validationResult = cacheControl.ObjectResults{}
@@ -93,76 +225,309 @@ func validateTheCacheControl(req *http.Request, resp *http.Response) (validation
 	return validationResult, nil
 }
 
-func (r *CacheHandler) roundTripRFCCompliance(req *http.Request) (resp *http.Response, err error) {
-	allowCache := allowedFromCache(req.Header)
-	if allowCache {
-		cachedResp, cachedItem, cachedErr := getCachedResponse(r.CacheInteractor, req)
-		if cachedResp != nil && cachedErr == nil {
-			buildTheCachedResponseHeader(cachedResp, cachedItem, r.CacheInteractor.Origin())
-			return cachedResp, cachedErr
+// RoundTrip the implementation of http.RoundTripper
+func (r *CacheHandler) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if r.Offline() {
+		return r.roundTripOffline(req)
+	}
+	if r.replay != ReplayDisabled {
+		return r.roundTripReplay(req)
+	}
+	rule := r.matchRule(req)
+	if (rule != nil && rule.Bypass) || bypass(req) {
+		return r.sendUpstream(req)
+	}
+	if !r.StorageHealthy() {
+		r.emit(Event{Type: EventStorageBypassed, Key: r.CacheKey(req), Request: req})
+		if r.graphQL != nil && r.graphQL.match(req) {
+			return r.roundTripGraphQLUnhealthy(req)
+		}
+		return r.sendUpstream(req)
+	}
+	if r.graphQL != nil && r.graphQL.match(req) {
+		var done bool
+		if req, resp, done, err = r.roundTripGraphQL(req); done {
+			return resp, err
 		}
-		// if error when getting from cachce, ignore it, re-try a live version
-		if cachedErr != nil {
-			log.Println(cachedErr, "failed to retrieve from cache, trying with a live version")
+	} else if r.bodyKey != nil && req.Method == http.MethodPost {
+		var ok bool
+		if req, ok, err = r.hashRequestBody(req); err != nil {
+			return nil, err
+		}
+		if !ok {
+			r.emit(Event{Type: EventCacheSkipped, Key: r.CacheKey(req), Request: req, Err: ErrRequestTooLarge})
+			return r.sendUpstream(req)
+		}
+	}
+	if !r.cachableMethod(req) {
+		// e.g the unsafe requests, they're never served from the cache storage
+		return r.sendUpstream(req)
+	}
+
+	var staleResp *http.Response
+	if (!r.ComplyRFC || allowedFromCache(req.Header)) && !forceRefresh(req) {
+		var cachedResp *http.Response
+		var ok bool
+		cachedResp, staleResp, ok = r.serveFromCache(req, rule)
+		if ok {
+			return serveRange(req, cachedResp)
 		}
 	}
 
-	resp, err = r.DefaultRoundTripper.RoundTrip(req)
+	// the range request is forwarded as is, its partial response is never stored
+	resp, err = r.sendUpstream(req)
+	if staleResp != nil && isUpstreamFailure(resp, err) {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return serveRange(req, r.serveStale(req, r.CacheKey(req), staleResp, cacheControl.WarningRevalidationFailed))
+	}
 	if err != nil {
 		return
 	}
 
-	validationResult, errValidation := validateTheCacheControl(req, resp)
-	if errValidation != nil {
-		log.Printf("Can't validate the response to RFC 7234, please check. Err: %v\n", errValidation)
-		return // return directly, not sure can be stored or not
+	r.cacheResponse(req, resp, rule)
+	// return err back to nil to make the call still success.
+	return resp, nil
+}
+
+// cachableMethod will check whether the request can be served from the cache storage, i.e the GET and HEAD
+// requests, and the POST requests keyed by their body or the GraphQL queries
+func (r *CacheHandler) cachableMethod(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		_, isGraphQLQuery := graphQLQuery(req)
+		return isGraphQLQuery || r.bodyHash(req) != ""
 	}
+	return false
+}
 
-	if validationResult.OutErr != nil {
-		log.Printf("Can't validate the response to RFC 7234, please check. Err: %v\n", validationResult.OutErr)
-		return // return directly, not sure can be stored or not
+// cacheResponse will store the response from the upstream if it's allowed by the RFC 7234 (when complied)
+// and the matching rule
+func (r *CacheHandler) cacheResponse(req *http.Request, resp *http.Response, rule *Rule) {
+	if req.Method == http.MethodHead {
+		r.updateFromHead(req, resp, rule)
+		return
+	}
+	if err := r.cachableStatus(resp.StatusCode); err != nil {
+		r.emit(Event{Type: EventCacheSkipped, Key: r.CacheKey(req), Request: req, Err: err})
+		return
+	}
+	_, isGraphQLQuery := graphQLQuery(req)
+	if isGraphQLQuery {
+		if err := checkGraphQLErrors(resp, r.maxResponseSize); err != nil {
+			r.emit(Event{Type: EventCacheSkipped, Key: r.CacheKey(req), Request: req, Err: err})
+			return
+		}
+	}
+	negativeTTL, negative := r.negativeTTL(resp.StatusCode)
+
+	now := time.Now()
+	var expiresAt time.Time
+	if r.ComplyRFC {
+		validationResult, errValidation := r.validateTheCacheControl(req, resp, now)
+		if errValidation != nil {
+			log.Printf("Can't validate the response to RFC 7234, please check. Err: %v\n", errValidation)
+			return // return directly, not sure can be stored or not
+		}
+
+		if validationResult.OutErr != nil {
+			log.Printf("Can't validate the response to RFC 7234, please check. Err: %v\n", validationResult.OutErr)
+			return // return directly, not sure can be stored or not
+		}
+
+		reasons := r.tolerate(validationResult.OutReasons)
+		if rule != nil {
+			reasons = rule.tolerate(reasons)
+		}
+		if _, ok := ttlOverride(req); negative || ok {
+			// the status is cachable for the configured TTL, like the Rule.TTL
+			reasons = removeReason(reasons, cacheControl.ReasonResponseUncachableByDefault)
+		}
+		if isGraphQLQuery {
+			// the query operation is safe, even when it's sent by POST
+			reasons = removeReason(reasons, cacheControl.ReasonRequestMethodPOST)
+		}
+		// reasons to not to cache
+		if len(reasons) > 0 {
+			log.Printf("Can't validate the response to RFC 7234, please check. Err: %v\n", reasons)
+			r.emit(Event{Type: EventCacheSkipped, Key: r.CacheKey(req), Request: req, Reasons: reasons})
+			return // return directly, not sure can be stored or not.
+		}
+		expiresAt = validationResult.OutExpirationTime
+	}
+	if rule != nil && rule.TTL > 0 {
+		expiresAt = now.Add(rule.TTL)
+	}
+	if negative {
+		expiresAt = now.Add(negativeTTL)
 	}
+	if ttl, ok := ttlOverride(req); ok {
+		expiresAt = now.Add(ttl)
+	}
+	if !r.ComplyRFC && expiresAt.IsZero() && resp.StatusCode >= http.StatusBadRequest {
+		// without the RFC 7234 compliance it has no freshness, it's cached for the default TTL
+		expiresAt = now.Add(DefaultNegativeTTL)
+	}
+
+	r.storeToCache(req, resp, expiresAt)
+}
 
-	// reasons to not to cache
-	if len(validationResult.OutReasons) > 0 {
-		log.Printf("Can't validate the response to RFC 7234, please check. Err: %v\n", validationResult.OutReasons)
-		return // return directly, not sure can be stored or not.
+// tolerate will remove the ignored reasons to not to cache
+func (r *CacheHandler) tolerate(reasons []cacheControl.Reason) []cacheControl.Reason {
+	if len(r.ignoredReasons) == 0 {
+		return reasons
 	}
+	kept := reasons[:0]
+	for _, reason := range reasons {
+		if !containsReason(r.ignoredReasons, reason) {
+			kept = append(kept, reason)
+		}
+	}
+	return kept
+}
 
-	err = storeRespToCache(r.CacheInteractor, req, resp)
-	if err != nil {
-		log.Printf("Can't store the response to database, please check. Err: %v\n", err)
+func removeReason(reasons []cacheControl.Reason, reason cacheControl.Reason) []cacheControl.Reason {
+	kept := reasons[:0]
+	for _, r := range reasons {
+		if r != reason {
+			kept = append(kept, r)
+		}
 	}
-	// return err back to nil to make the call still success.
-	return resp, nil
+	return kept
 }
 
-// RoundTrip the implementation of http.RoundTripper
-func (r *CacheHandler) RoundTrip(req *http.Request) (resp *http.Response, err error) {
-	if r.ComplyRFC {
-		return r.roundTripRFCCompliance(req)
+func containsReason(reasons []cacheControl.Reason, reason cacheControl.Reason) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
 	}
-	cachedResp, cachedItem, cachedErr := getCachedResponse(r.CacheInteractor, req)
-	if cachedResp != nil && cachedErr == nil {
+	return false
+}
+
+// serveFromCache will try to retrieve a fresh response from the cache storage. A stale response is
+// served while it's revalidated in the background, or returned as the staleResp to be served if the
+// upstream fails, according to the stale windows.
+func (r *CacheHandler) serveFromCache(req *http.Request, rule *Rule) (resp, staleResp *http.Response, ok bool) {
+	key := r.CacheKey(req)
+	cachedResp, cachedItem, expiresAt, cachedErr := r.lookupCachedResponse(req, key)
+	if cachedErr == nil {
+		now := time.Now()
+		fresh := !now.After(expiresAt)
+		if fresh && r.ComplyRFC && (rule == nil || rule.TTL == 0) {
+			// before the headers of this library are added to the stored response
+			r.addHeuristicWarning(req, cachedResp, now)
+		}
 		buildTheCachedResponseHeader(cachedResp, cachedItem, r.CacheInteractor.Origin())
-		return cachedResp, cachedErr
+		if fresh {
+			eventType := EventCacheHit
+			if cachedResp.StatusCode >= http.StatusBadRequest {
+				eventType = EventCacheNegativeHit
+			}
+			r.emit(Event{Type: eventType, Key: key, Request: req})
+			return cachedResp, nil, true
+		}
+
+		whileRevalidate, ifError := r.staleWindows(cachedResp, rule)
+		if now.Before(expiresAt.Add(whileRevalidate)) {
+			r.revalidate(req, key, rule)
+			return r.serveStale(req, key, cachedResp, cacheControl.WarningResponseIsStale), nil, true
+		}
+		if now.Before(expiresAt.Add(ifError)) || r.staleWhileOpen(req, cachedResp) {
+			staleResp = cachedResp
+		}
+		cachedErr = fmt.Errorf("cached-item already expired")
 	}
+
 	// if error when getting from cachce, ignore it, re-try a live version
-	if cachedErr != nil {
-		log.Println(cachedErr, "failed to retrieve from cache, trying with a live version")
+	log.Println(cachedErr, "failed to retrieve from cache, trying with a live version")
+	if errors.Is(cachedErr, cache.ErrStorageInternal) {
+		r.emit(Event{Type: EventStorageError, Key: key, Request: req, Err: cachedErr})
 	}
+	r.emit(Event{Type: EventCacheMiss, Key: key, Request: req})
+	return nil, staleResp, false
+}
 
-	resp, err = r.DefaultRoundTripper.RoundTrip(req)
-	if err != nil {
+// storeToCache will store the response to the cache storage, the failure is only logged
+// to make the call still success. A zero expiresAt means the freshness is calculated from
+// the stored response headers when it's retrieved.
+func (r *CacheHandler) storeToCache(req *http.Request, resp *http.Response, expiresAt time.Time) {
+	key := r.CacheKey(req)
+	if r.maxResponseSize > 0 && resp.ContentLength > r.maxResponseSize {
+		// skip it before reading the body
+		r.emit(Event{Type: EventCacheSkipped, Key: key, Request: req, Err: ErrResponseTooLarge})
 		return
 	}
+	if r.async != nil && !awaitsStoreOutcome(req) {
+		r.storeAsync(req, resp, key, expiresAt)
+		return
+	}
+	r.store(req, resp, key, expiresAt)
+}
 
-	err = storeRespToCache(r.CacheInteractor, req, resp)
+// store will store the response to the cache storage with the key
+func (r *CacheHandler) store(req *http.Request, resp *http.Response, key string, expiresAt time.Time) {
+	err := storeRespToCache(r.CacheInteractor, req, resp, key, expiresAt, r.maxResponseSize)
+	r.recordStorage(req, err)
+	if errors.Is(err, cache.ErrFailedToSaveToCache) || errors.Is(err, ErrResponseTooLarge) {
+		// refused by the storage or the handler, e.g the response is too large
+		r.emit(Event{Type: EventCacheSkipped, Key: key, Request: req, Err: err})
+		return
+	}
 	if err != nil {
 		log.Printf("Can't store the response to database, please check. Err: %v\n", err)
-		err = nil // set err back to nil to make the call still success.
+		r.emit(Event{Type: EventStorageError, Key: key, Request: req, Err: err})
+		return
 	}
-	return
+	r.indexTags(req, key, resp.Header)
+	r.emit(Event{Type: EventCacheStored, Key: key, Request: req})
+}
+
+func (r *CacheHandler) emit(ev Event) {
+	if r.Observer != nil {
+		r.Observer.OnEvent(ev)
+	}
+	if ev.Request == nil {
+		return
+	}
+	if recorder, ok := ev.Request.Context().Value(eventRecorderKey{}).(Observer); ok {
+		recorder.OnEvent(ev)
+	}
+}
+
+// CacheKey will return the key used to store the response of the request in the cache storage.
+// The HEAD request has the key of the GET request, it's answered from the stored GET response.
+func (r *CacheHandler) CacheKey(req *http.Request) string {
+	if req.Method == http.MethodHead {
+		req = withMethod(req, http.MethodGet)
+	}
+	if key, ok := requestCacheKey(req); ok && req.Method == http.MethodGet {
+		// the key replaces the URL only, the other methods and the partitions never share it
+		return formatCacheKey(req.Method, key, r.partition(req))
+	}
+	if r.keyFunc != nil {
+		// the custom key is still partitioned, the partitions never share it
+		return partitionKey(r.keyFunc(req), r.partition(req))
+	}
+	if op, ok := graphQLQuery(req); ok {
+		return graphQLKey(req, op, r.partition(req))
+	}
+	if rule := r.matchRule(req); rule != nil {
+		req = rule.stripQuery(req)
+	}
+	key := getCacheKey(req, r.partition(req))
+	if hash := r.bodyHash(req); hash != "" {
+		key = fmt.Sprintf("%s body=%s", key, hash)
+	}
+	return key
+}
+
+// Mode will return whether the cache is shared or private
+func (r *CacheHandler) Mode() CacheMode {
+	return r.mode
 }
 
 // RFC7234Compliance used for enable/disable the RFC 7234 compliance
@@ -171,66 +536,103 @@ func (r *CacheHandler) RFC7234Compliance(val bool) *CacheHandler {
 	return r
 }
 
-func storeRespToCache(cacheInteractor cache.ICacheInteractor, req *http.Request, resp *http.Response) (err error) {
+func storeRespToCache(cacheInteractor cache.ICacheInteractor, req *http.Request, resp *http.Response,
+	key string, expiresAt time.Time, maxSize int64) (err error) {
 	cachedResp := cache.CachedResponse{
 		RequestMethod: req.Method,
-		RequestURI:    req.RequestURI,
+		RequestURI:    req.URL.String(),
 		CachedTime:    time.Now(),
+		ExpiresAt:     expiresAt,
+		Tags:          requestTags(req),
 	}
 
 	dumpedResponse, err := httputil.DumpResponse(resp, true)
 	if err != nil {
 		return
 	}
+	if maxSize > 0 && int64(len(dumpedResponse)) > maxSize {
+		return ErrResponseTooLarge
+	}
 	cachedResp.DumpedResponse = dumpedResponse
 
-	err = cacheInteractor.Set(getCacheKey(req), cachedResp)
+	err = cacheInteractor.Set(key, cachedResp)
 	return
 }
 
-func getCachedResponse(cacheInteractor cache.ICacheInteractor, req *http.Request) (
-	resp *http.Response, cachedResp cache.CachedResponse, err error) {
-	cachedResp, err = cacheInteractor.Get(getCacheKey(req))
+// lookupCachedResponse will retrieve the stored response regardless its freshness,
+// along with the time the stored response become stale.
+func (r *CacheHandler) lookupCachedResponse(req *http.Request, key string) (
+	resp *http.Response, cachedResp cache.CachedResponse, expiresAt time.Time, err error) {
+	resp, cachedResp, err = r.readStoredResponse(req, key)
 	if err != nil {
 		return
 	}
 
-	cachedResponse := bytes.NewBuffer(cachedResp.DumpedResponse)
-	resp, err = http.ReadResponse(bufio.NewReader(cachedResponse), req)
-	if err != nil {
+	if !cachedResp.ExpiresAt.IsZero() {
+		expiresAt = cachedResp.ExpiresAt
 		return
 	}
 
-	validationResult, err := validateTheCacheControl(req, resp)
+	// the expiration time is calculated since the response is stored
+	validationResult, err := r.validateTheCacheControl(req, resp, cachedResp.CachedTime)
 	if err != nil {
 		return
 	}
 
 	if validationResult.OutErr != nil {
+		err = validationResult.OutErr
 		return
 	}
 
-	if time.Now().After(validationResult.OutExpirationTime) {
-		err = fmt.Errorf("cached-item already expired")
+	expiresAt = validationResult.OutExpirationTime
+	return
+}
+
+// readStoredResponse will read the stored response, its headers are not validated
+func (r *CacheHandler) readStoredResponse(req *http.Request, key string) (
+	resp *http.Response, cachedResp cache.CachedResponse, err error) {
+	cachedResp, err = r.CacheInteractor.Get(key)
+	r.recordStorage(req, err)
+	if err != nil {
 		return
 	}
-
+	resp, err = http.ReadResponse(bufio.NewReader(bytes.NewReader(cachedResp.DumpedResponse)), req)
 	return
 }
 
-func getCacheKey(req *http.Request) (key string) {
-	key = fmt.Sprintf("%s %s", req.Method, req.RequestURI)
-	if (strings.ToLower(req.Header.Get(HeaderCacheControl)) == "private") &&
-		req.Header.Get(HeaderAuthorization) != "" {
-		key = fmt.Sprintf("%s %s", key, req.Header.Get(HeaderAuthorization))
+func getCacheKey(req *http.Request, partition string) (key string) {
+	// the request URL is used instead of the RequestURI, the RequestURI is always empty in client requests
+	return formatCacheKey(req.Method, req.URL.String(), partition)
+}
+
+func formatCacheKey(method, target, partition string) (key string) {
+	return partitionKey(fmt.Sprintf("%s %s", method, target), partition)
+}
+
+// partitionKey will add the hashed partition ID to the key, if the request is partitioned
+func partitionKey(key, partition string) string {
+	if partition == "" {
+		return key
 	}
-	return
+	return fmt.Sprintf("%s partition=%s", key, partition)
 }
 
-// buildTheCachedResponse will finalize the response header
+// buildTheCachedResponse will finalize the response header. The Age is the time the response is resident
+// in the cache storage added to its age when it's stored (RFC 7234 section 4.2.3), and the Expires is the
+// expiration time of the stored response if it's known.
 func buildTheCachedResponseHeader(resp *http.Response,
 	cachedResp cache.CachedResponse, origin string) { //nolint
-	resp.Header.Add("Expires", cachedResp.CachedTime.String())
+	age := time.Since(cachedResp.CachedTime)
+	if initial, err := strconv.ParseInt(resp.Header.Get(HeaderAge), 10, 64); err == nil && initial > 0 {
+		age += time.Duration(initial) * time.Second
+	}
+	if age < 0 {
+		age = 0
+	}
+	resp.Header.Set(HeaderAge, strconv.FormatInt(int64(age/time.Second), 10))
+	if !cachedResp.ExpiresAt.IsZero() {
+		resp.Header.Set(HeaderExpires, cachedResp.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
 	resp.Header.Add(XFromHache, "true")
 	resp.Header.Add(XHacheOrigin, origin)
 	// TODO: (bxcodec) add more headers related to cache
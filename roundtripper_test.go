@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/bxcodec/httpcache"
 	"github.com/bxcodec/httpcache/cache"
+	"github.com/bxcodec/httpcache/cache/inmem"
 	"github.com/bxcodec/httpcache/mocks"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -21,7 +25,9 @@ func TestSetToCacheRoundtrip(t *testing.T) {
 	mockCacheInteractor.On("Get", mock.AnythingOfType("string")).Once().Return(cachedResponse, errors.New("uknown error"))
 	mockCacheInteractor.On("Set", mock.AnythingOfType("string"), mock.Anything).Once().Return(nil)
 	client := &http.Client{}
-	client.Transport = httpcache.NewCacheHandlerRoundtrip(http.DefaultTransport, true, mockCacheInteractor)
+	cacheHandler, err := httpcache.NewCacheHandlerRoundtrip(http.DefaultTransport, true, mockCacheInteractor)
+	require.NoError(t, err)
+	client.Transport = cacheHandler
 	// HTTP GET 200
 	jsonResp := []byte(`{"message": "Hello World!"}`)
 	handler := func() (res http.Handler) {
@@ -47,3 +53,27 @@ func TestSetToCacheRoundtrip(t *testing.T) {
 	require.Empty(t, resp.Header.Get(httpcache.XHacheOrigin))
 	mockCacheInteractor.AssertExpectations(t)
 }
+
+func TestCachedResponseHeaders(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set(httpcache.HeaderAge, "10")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	client := &http.Client{}
+	_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}))
+	require.NoError(t, err)
+
+	doGet(t, client, upstream.URL+"/a")
+	resp, _ := doGet(t, client, upstream.URL+"/a")
+	require.Equal(t, "true", resp.Header.Get(httpcache.XFromHache))
+	require.Len(t, resp.Header.Values(httpcache.HeaderAge), 1)
+	age, err := strconv.Atoi(resp.Header.Get(httpcache.HeaderAge))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, age, 10)
+	require.Len(t, resp.Header.Values(httpcache.HeaderExpires), 1)
+	expires, err := http.ParseTime(resp.Header.Get(httpcache.HeaderExpires))
+	require.NoError(t, err)
+	require.True(t, expires.After(time.Now()))
+	require.False(t, expires.After(time.Now().Add(time.Minute)))
+}
@@ -0,0 +1,194 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+	"gopkg.in/yaml.v2"
+)
+
+// Rule is a per-route policy that overrides how the responses are cached.
+// A rule with no matcher matches every request.
+type Rule struct {
+	Name string `yaml:"name"` // Optional, only used in the errors
+
+	// Matchers
+	Host      string   `yaml:"host"`       // Optional, glob of the request host without the port, e.g "*.example.com"
+	Path      string   `yaml:"path"`       // Optional, glob of the request path, e.g "/api/*". A trailing "/**" matches every sub path
+	PathRegex string   `yaml:"path_regex"` // Optional, regular expression of the request path, can't be used along with the Path
+	Methods   []string `yaml:"methods"`    // Optional, e.g [GET, HEAD]
+
+	// Actions
+	Bypass               bool          `yaml:"bypass"`                 // Never serve from or store to the cache storage
+	TTL                  time.Duration `yaml:"ttl"`                    // Force the freshness lifetime, the stored response is cachable regardless the heuristics
+	IgnoreNoStore        bool          `yaml:"ignore_no_store"`        // Store the response even if the origin sends no-store
+	IgnorePrivate        bool          `yaml:"ignore_private"`         // Store the private response even if the cache is shared
+	StaleWhileRevalidate time.Duration `yaml:"stale_while_revalidate"` // Serve the stale response while it's revalidated in the background
+	StaleIfError         time.Duration `yaml:"stale_if_error"`         // Serve the stale response when the upstream fails
+	ExcludeQueryParams   []string      `yaml:"exclude_query_params"`   // Glob of the query params not used in the key, e.g "utm_*"
+
+	pathRegex *regexp.Regexp
+}
+
+// WithRules will apply the rules to the matching requests, the first matching rule wins.
+// The rules are validated when the handler is created.
+func WithRules(rules ...Rule) Option {
+	return func(h *CacheHandler) {
+		h.rules = append(h.rules, rules...)
+	}
+}
+
+// ParseRules will parse the rules from a YAML document, or a JSON document as JSON is a subset of YAML.
+// The rules are listed under the "rules" field, e.g:
+//
+//	rules:
+//	  - host: api.example.com
+//	    path: /v1/products/**
+//	    ttl: 5m
+//	    stale_if_error: 1h
+//	  - path: /v1/cart/**
+//	    bypass: true
+func ParseRules(raw []byte) ([]Rule, error) {
+	doc := struct {
+		Rules []Rule `yaml:"rules"`
+	}{}
+	if err := yaml.UnmarshalStrict(raw, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOption, err)
+	}
+	for i := range doc.Rules {
+		if err := doc.Rules[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+	return doc.Rules, nil
+}
+
+// LoadRules will read and parse the rules file, see ParseRules
+func LoadRules(file string) ([]Rule, error) {
+	raw, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(raw)
+}
+
+// compile will validate the rule and prepare its matchers
+func (rule *Rule) compile() (err error) {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: rule %q: %s", ErrInvalidOption, rule.Name, fmt.Sprintf(format, args...))
+	}
+	if rule.Path != "" && rule.PathRegex != "" {
+		return invalid("the path and the path_regex can't be used together")
+	}
+	globs := append([]string{rule.Host, strings.TrimSuffix(rule.Path, "**")}, rule.ExcludeQueryParams...)
+	for _, glob := range globs {
+		if _, err = path.Match(glob, ""); err != nil {
+			return invalid("invalid glob %q", glob)
+		}
+	}
+	if rule.PathRegex != "" {
+		if rule.pathRegex, err = regexp.Compile(rule.PathRegex); err != nil {
+			return invalid("%v", err)
+		}
+	}
+	if rule.TTL < 0 || rule.StaleWhileRevalidate < 0 || rule.StaleIfError < 0 {
+		return invalid("the durations must not be negative")
+	}
+	return nil
+}
+
+// match will check whether the request match the rule
+func (rule *Rule) match(req *http.Request) bool {
+	if len(rule.Methods) > 0 && !containsFold(rule.Methods, req.Method) {
+		return false
+	}
+	if rule.Host != "" {
+		host := req.URL.Hostname()
+		if host == "" {
+			host = req.Host
+		}
+		if ok, _ := path.Match(strings.ToLower(rule.Host), strings.ToLower(host)); !ok {
+			return false
+		}
+	}
+	switch {
+	case rule.pathRegex != nil:
+		return rule.pathRegex.MatchString(req.URL.Path)
+	case strings.HasSuffix(rule.Path, "**"):
+		return strings.HasPrefix(req.URL.Path, strings.TrimSuffix(rule.Path, "**"))
+	case rule.Path != "":
+		ok, _ := path.Match(rule.Path, req.URL.Path)
+		return ok
+	}
+	return true
+}
+
+// excludedQueryParam will check whether the query param is not used in the key
+func (rule *Rule) excludedQueryParam(name string) bool {
+	for _, glob := range rule.ExcludeQueryParams {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stripQuery will return a shallow copy of the request without the excluded query params
+func (rule *Rule) stripQuery(req *http.Request) *http.Request {
+	if len(rule.ExcludeQueryParams) == 0 || req.URL.RawQuery == "" {
+		return req
+	}
+	u := *req.URL
+	query := u.Query()
+	for name := range query {
+		if rule.excludedQueryParam(name) {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode()
+	stripped := new(http.Request)
+	*stripped = *req
+	stripped.URL = &u
+	return stripped
+}
+
+// tolerate will remove the reasons to not to cache that are overridden by the rule
+func (rule *Rule) tolerate(reasons []cacheControl.Reason) []cacheControl.Reason {
+	kept := reasons[:0]
+	for _, reason := range reasons {
+		switch {
+		case reason == cacheControl.ReasonResponseNoStore && rule.IgnoreNoStore,
+			reason == cacheControl.ReasonResponsePrivate && rule.IgnorePrivate,
+			reason == cacheControl.ReasonResponseUncachableByDefault && rule.TTL > 0:
+			continue
+		}
+		kept = append(kept, reason)
+	}
+	return kept
+}
+
+// matchRule will return the first rule matching the request, or nil if none
+func (r *CacheHandler) matchRule(req *http.Request) *Rule {
+	for i := range r.rules {
+		if r.rules[i].match(req) {
+			return &r.rules[i]
+		}
+	}
+	return nil
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package httpcache_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := httpcache.ParseRules([]byte(`
+rules:
+  - name: products
+    host: "*.example.com"
+    path: /v1/products/**
+    methods: [GET]
+    ttl: 5m
+    stale_if_error: 1h
+    exclude_query_params: ["utm_*"]
+  - path_regex: ^/v1/cart
+    bypass: true
+`))
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Equal(t, 5*time.Minute, rules[0].TTL)
+	require.Equal(t, time.Hour, rules[0].StaleIfError)
+	require.True(t, rules[1].Bypass)
+
+	// JSON is a subset of YAML
+	rules, err = httpcache.ParseRules([]byte(`{"rules": [{"path": "/static/*", "ttl": "30s", "ignore_no_store": true}]}`))
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, rules[0].TTL)
+	require.True(t, rules[0].IgnoreNoStore)
+
+	for _, raw := range []string{
+		`rules: [{path: /a, path_regex: ^/a}]`,
+		`rules: [{path_regex: "("}]`,
+		`rules: [{path: "[a"}]`,
+		`rules: [{ttl: -1s}]`,
+		`rules: [{unknown: true}]`,
+	} {
+		_, err = httpcache.ParseRules([]byte(raw))
+		require.True(t, errors.Is(err, httpcache.ErrInvalidOption), raw)
+	}
+
+	_, err = httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithRules(httpcache.Rule{PathRegex: "("}))
+	require.True(t, errors.Is(err, httpcache.ErrInvalidOption), err)
+}
+
+func TestRules(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		if strings.HasPrefix(r.URL.Path, "/nostore") {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		_, _ = io.WriteString(w, r.URL.RawQuery)
+	})
+
+	for _, tc := range []struct {
+		name         string
+		rule         httpcache.Rule
+		targets      []string
+		expectedHits int64
+	}{
+		{
+			name:         "without rule",
+			targets:      []string{"/a", "/a"},
+			expectedHits: 2,
+		},
+		{
+			name:         "force ttl",
+			rule:         httpcache.Rule{Path: "/a", TTL: time.Minute},
+			targets:      []string{"/a", "/a", "/b"},
+			expectedHits: 2,
+		},
+		{
+			name:         "ignore no-store",
+			rule:         httpcache.Rule{Path: "/nostore/**", TTL: time.Minute, IgnoreNoStore: true},
+			targets:      []string{"/nostore/a", "/nostore/a"},
+			expectedHits: 1,
+		},
+		{
+			name:         "no-store",
+			rule:         httpcache.Rule{Path: "/nostore/**", TTL: time.Minute},
+			targets:      []string{"/nostore/a", "/nostore/a"},
+			expectedHits: 2,
+		},
+		{
+			name:         "exclude query params",
+			rule:         httpcache.Rule{TTL: time.Minute, ExcludeQueryParams: []string{"utm_*"}},
+			targets:      []string{"/a?id=1&utm_source=x", "/a?id=1&utm_medium=y", "/a?id=2"},
+			expectedHits: 2,
+		},
+		{
+			name:         "method mismatch",
+			rule:         httpcache.Rule{Methods: []string{http.MethodHead}, TTL: time.Minute},
+			targets:      []string{"/a", "/a"},
+			expectedHits: 2,
+		},
+		{
+			name:         "host mismatch",
+			rule:         httpcache.Rule{Host: "*.example.com", TTL: time.Minute},
+			targets:      []string{"/a", "/a"},
+			expectedHits: 2,
+		},
+		{
+			name:         "bypass",
+			rule:         httpcache.Rule{Path: "/a", Bypass: true},
+			targets:      []string{"/a", "/a"},
+			expectedHits: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt64(&hits, 0)
+			client := &http.Client{}
+			_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+				httpcache.WithRules(tc.rule))
+			require.NoError(t, err)
+
+			for _, target := range tc.targets {
+				doGet(t, client, upstream.URL+target)
+			}
+			require.Equal(t, tc.expectedHits, atomic.LoadInt64(&hits))
+		})
+	}
+}
+
+func TestRulesStaleWindows(t *testing.T) {
+	var hits, failing int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&hits, 1)
+		if atomic.LoadInt64(&failing) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = io.WriteString(w, strings.Repeat("x", int(n)))
+	})
+
+	t.Run("stale if error", func(t *testing.T) {
+		atomic.StoreInt64(&hits, 0)
+		stats := &httpcache.Stats{}
+		client := &http.Client{}
+		_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+			httpcache.WithObserver(stats),
+			httpcache.WithRules(httpcache.Rule{TTL: 10 * time.Millisecond, StaleIfError: time.Minute}))
+		require.NoError(t, err)
+
+		_, body := doGet(t, client, upstream.URL+"/sie")
+		require.Equal(t, "x", body)
+		time.Sleep(20 * time.Millisecond)
+
+		atomic.StoreInt64(&failing, 1)
+		defer atomic.StoreInt64(&failing, 0)
+		resp, body := doGet(t, client, upstream.URL+"/sie")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "x", body)
+		require.True(t, strings.HasPrefix(resp.Header.Get(httpcache.HeaderWarning), "111 "))
+		require.EqualValues(t, 1, stats.Count(httpcache.EventCacheStale))
+		require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+	})
+
+	t.Run("stale while revalidate", func(t *testing.T) {
+		atomic.StoreInt64(&hits, 0)
+		client := &http.Client{}
+		_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+			httpcache.WithRules(httpcache.Rule{TTL: 10 * time.Millisecond, StaleWhileRevalidate: time.Minute}))
+		require.NoError(t, err)
+
+		doGet(t, client, upstream.URL+"/swr")
+		time.Sleep(20 * time.Millisecond)
+
+		resp, body := doGet(t, client, upstream.URL+"/swr")
+		require.Equal(t, "x", body)
+		require.True(t, strings.HasPrefix(resp.Header.Get(httpcache.HeaderWarning), "110 "))
+
+		// the stored response is refreshed in the background
+		require.Eventually(t, func() bool {
+			_, body := doGet(t, client, upstream.URL+"/swr")
+			return body == "xx"
+		}, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestStaleNotServedWhenMustRevalidate(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&hits, 1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=0, must-revalidate, stale-if-error=60")
+		_, _ = io.WriteString(w, "ok")
+	})
+
+	client := &http.Client{}
+	_, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		httpcache.WithRules(httpcache.Rule{TTL: time.Millisecond}))
+	require.NoError(t, err)
+
+	doGet(t, client, upstream.URL)
+	time.Sleep(5 * time.Millisecond)
+	resp, _ := doGet(t, client, upstream.URL)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -0,0 +1,140 @@
+package httpcache
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bxcodec/httpcache/cache"
+)
+
+// SnapshotVersion is the version of the snapshot format written by ExportSnapshot
+const SnapshotVersion = 1
+
+// ErrInvalidSnapshot will throw if the snapshot can't be read, e.g it's corrupted or of an unknown version
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// snapshotHeader is the first line of a snapshot
+type snapshotHeader struct {
+	Version   int       `json:"version"`
+	Origin    string    `json:"origin"` // The storage type the snapshot is exported from
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// snapshotRecord is a stored item of a snapshot, one per line after the header
+type snapshotRecord struct {
+	Key  string               `json:"key"`
+	Item cache.CachedResponse `json:"item"`
+}
+
+// ImportOptions is the configuration of ImportSnapshot
+type ImportOptions struct {
+	// SkipExpired will skip the items already stale, the items without an expiration time are always imported
+	SkipExpired bool
+}
+
+// ExportSnapshot will write all the items of the cache storage to the writer, as gzip'd JSON lines.
+// The cache storage must implement the cache.KeyLister. The items evicted while it's exported are skipped,
+// and the keys that aren't stored responses, e.g of another application sharing the storage, are skipped
+// and counted as skipped.
+func ExportSnapshot(ctx context.Context, w io.Writer, store cache.ICacheInteractor) (exported, skipped int, err error) {
+	lister, ok := store.(cache.KeyLister)
+	if !ok {
+		return 0, 0, cache.ErrNotSupported
+	}
+	keys, err := lister.Keys(ctx, "")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	if err = encoder.Encode(snapshotHeader{
+		Version:   SnapshotVersion,
+		Origin:    store.Origin(),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		return 0, 0, err
+	}
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			return exported, skipped, err
+		}
+		item, errGet := store.Get(key)
+		if errors.Is(errGet, cache.ErrCacheMissed) {
+			continue
+		}
+		if errors.Is(errGet, cache.ErrInvalidCachedResponse) {
+			skipped++
+			continue
+		}
+		if errGet != nil {
+			return exported, skipped, errGet
+		}
+		if err = encoder.Encode(snapshotRecord{Key: key, Item: item}); err != nil {
+			return exported, skipped, err
+		}
+		exported++
+	}
+	return exported, skipped, gz.Close()
+}
+
+// ImportSnapshot will store the items of the snapshot written by ExportSnapshot to the cache storage,
+// and index their tags if the cache storage implements the cache.TagIndexer. The items refused by
+// the cache storage, e.g too large, are skipped.
+func ImportSnapshot(ctx context.Context, r io.Reader, store cache.ICacheInteractor, opts ImportOptions) (imported int, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	defer gz.Close() //nolint
+	decoder := json.NewDecoder(gz)
+
+	var header snapshotHeader
+	if err = decoder.Decode(&header); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if header.Version != SnapshotVersion {
+		return 0, fmt.Errorf("%w: unknown version %d", ErrInvalidSnapshot, header.Version)
+	}
+
+	indexer, _ := store.(cache.TagIndexer)
+	now := time.Now()
+	for {
+		if err = ctx.Err(); err != nil {
+			return imported, err
+		}
+		var record snapshotRecord
+		err = decoder.Decode(&record)
+		if err == io.EOF {
+			return imported, nil
+		}
+		if err != nil {
+			return imported, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		if opts.SkipExpired && !record.Item.ExpiresAt.IsZero() && record.Item.ExpiresAt.Before(now) {
+			continue
+		}
+
+		err = store.Set(record.Key, record.Item)
+		if errors.Is(err, cache.ErrFailedToSaveToCache) {
+			continue
+		}
+		if err != nil {
+			return imported, err
+		}
+		imported++
+		if indexer == nil {
+			continue
+		}
+		if tags := itemTags(record.Item); len(tags) > 0 {
+			if err = indexer.AddTags(ctx, record.Key, tags); err != nil {
+				return imported, err
+			}
+		}
+	}
+}
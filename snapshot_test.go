@@ -0,0 +1,126 @@
+package httpcache_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache"
+	"github.com/bxcodec/httpcache/cache/disk"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set(httpcache.HeaderSurrogateKey, "product")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+
+	source := inmem.NewBoundedCache(inmem.BoundedCacheOptions{})
+	sourceClient := &http.Client{}
+	_, err := httpcache.New(sourceClient, source)
+	require.NoError(t, err)
+	doGet(t, sourceClient, upstream.URL+"/products/1")
+	doGet(t, sourceClient, upstream.URL+"/products/2")
+
+	var snapshot bytes.Buffer
+	exported, skipped, err := httpcache.ExportSnapshot(context.TODO(), &snapshot, source)
+	require.NoError(t, err)
+	require.Equal(t, 2, exported)
+	require.Zero(t, skipped)
+
+	for name, target := range map[string]cache.ICacheInteractor{
+		"inmem": inmem.NewBoundedCache(inmem.BoundedCacheOptions{}),
+		"disk":  disk.NewCache(t.TempDir(), 0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			imported, err := httpcache.ImportSnapshot(context.TODO(), bytes.NewReader(snapshot.Bytes()), target,
+				httpcache.ImportOptions{})
+			require.NoError(t, err)
+			require.Equal(t, 2, imported)
+
+			// served from the imported items, without a cold start
+			client := &http.Client{}
+			handler, err := httpcache.New(client, target)
+			require.NoError(t, err)
+			resp, body := doGet(t, client, upstream.URL+"/products/1")
+			require.Equal(t, "/products/1", body)
+			require.Equal(t, "true", resp.Header.Get(httpcache.XFromHache))
+			require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+
+			purged, err := handler.PurgeTag(context.TODO(), "product")
+			require.NoError(t, err)
+			require.Equal(t, 2, purged)
+		})
+	}
+}
+
+func TestImportSnapshotSkipExpired(t *testing.T) {
+	source := inmem.NewBoundedCache(inmem.BoundedCacheOptions{})
+	for key, expiresAt := range map[string]time.Time{
+		"GET http://example.com/fresh":   time.Now().Add(time.Minute),
+		"GET http://example.com/stale":   time.Now().Add(-time.Minute),
+		"GET http://example.com/unknown": {},
+	} {
+		require.NoError(t, source.Set(key, cache.CachedResponse{
+			DumpedResponse: []byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"),
+			RequestURI:     strings.TrimPrefix(key, "GET "),
+			RequestMethod:  http.MethodGet,
+			CachedTime:     time.Now(),
+			ExpiresAt:      expiresAt,
+		}))
+	}
+	var snapshot bytes.Buffer
+	_, _, err := httpcache.ExportSnapshot(context.TODO(), &snapshot, source)
+	require.NoError(t, err)
+
+	target := inmem.NewBoundedCache(inmem.BoundedCacheOptions{})
+	imported, err := httpcache.ImportSnapshot(context.TODO(), &snapshot, target, httpcache.ImportOptions{SkipExpired: true})
+	require.NoError(t, err)
+	require.Equal(t, 2, imported)
+	_, err = target.Get("GET http://example.com/stale")
+	require.True(t, errors.Is(err, cache.ErrCacheMissed), err)
+}
+
+func TestImportSnapshotInvalid(t *testing.T) {
+	_, err := httpcache.ImportSnapshot(context.TODO(), strings.NewReader("not a snapshot"),
+		inmem.NewBoundedCache(inmem.BoundedCacheOptions{}), httpcache.ImportOptions{})
+	require.True(t, errors.Is(err, httpcache.ErrInvalidSnapshot), err)
+
+	_, _, err = httpcache.ExportSnapshot(context.TODO(), io.Discard, NewCustomInMemStorage())
+	require.True(t, errors.Is(err, cache.ErrNotSupported), err)
+}
+
+// foreignKeyStorage has a key that isn't a stored response
+type foreignKeyStorage struct {
+	*inmem.BoundedCache
+}
+
+func (s foreignKeyStorage) Get(key string) (cache.CachedResponse, error) {
+	if key == "session:1" {
+		return cache.CachedResponse{}, cache.ErrInvalidCachedResponse
+	}
+	return s.BoundedCache.Get(key)
+}
+
+func TestExportSnapshotSkipForeignKeys(t *testing.T) {
+	source := foreignKeyStorage{BoundedCache: inmem.NewBoundedCache(inmem.BoundedCacheOptions{})}
+	for _, key := range []string{"GET http://example.com/", "session:1"} {
+		require.NoError(t, source.Set(key, cache.CachedResponse{CachedTime: time.Now()}))
+	}
+	exported, skipped, err := httpcache.ExportSnapshot(context.TODO(), io.Discard, source)
+	require.NoError(t, err)
+	require.Equal(t, 1, exported)
+	require.Equal(t, 1, skipped)
+}
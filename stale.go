@@ -0,0 +1,108 @@
+package httpcache
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+)
+
+// HeaderWarning is the header carrying the warnings of a served response, e.g the response is stale
+const HeaderWarning = "Warning"
+
+// staleWindows will return how long the stored response can be served after it become stale,
+// while it's revalidated in the background and when the upstream fails. The windows of the rule
+// take precedence over the stale-while-revalidate and stale-if-error directives of the response.
+func (r *CacheHandler) staleWindows(resp *http.Response, rule *Rule) (whileRevalidate, ifError time.Duration) {
+	if rule != nil {
+		whileRevalidate, ifError = rule.StaleWhileRevalidate, rule.StaleIfError
+	}
+	if !r.ComplyRFC {
+		return
+	}
+	resDir, err := cacheControl.ParseResponseCacheControl(resp.Header.Get(HeaderCacheControl))
+	if err != nil || r.staleForbidden(resDir) {
+		return 0, 0
+	}
+	if whileRevalidate == 0 && resDir.StaleWhileRevalidate > 0 {
+		whileRevalidate = time.Duration(resDir.StaleWhileRevalidate) * time.Second
+	}
+	if ifError == 0 && resDir.StaleIfError > 0 {
+		ifError = time.Duration(resDir.StaleIfError) * time.Second
+	}
+	return
+}
+
+// staleForbidden will check whether the stale response must not be served without a successful validation
+func (r *CacheHandler) staleForbidden(resDir *cacheControl.ResponseCacheDirectives) bool {
+	return resDir.MustRevalidate || resDir.NoCachePresent ||
+		(r.mode == SharedCache && (resDir.ProxyRevalidate || resDir.SMaxAge != -1))
+}
+
+// staleWhileOpen will check whether the stale response can be served while the circuit breaker
+// of the upstream host is open, i.e the upstream is considered unreachable
+func (r *CacheHandler) staleWhileOpen(req *http.Request, resp *http.Response) bool {
+	if r.circuitClosed(req) {
+		return false
+	}
+	if !r.ComplyRFC {
+		return true
+	}
+	resDir, err := cacheControl.ParseResponseCacheControl(resp.Header.Get(HeaderCacheControl))
+	return err == nil && !r.staleForbidden(resDir)
+}
+
+// serveStale will mark the stored response as stale before serving it
+func (r *CacheHandler) serveStale(req *http.Request, key string, resp *http.Response, warning cacheControl.Warning) *http.Response {
+	resp.Header.Add(HeaderWarning, warning.HeaderString("", time.Now()))
+	r.emit(Event{Type: EventCacheStale, Key: key, Request: req})
+	return resp
+}
+
+// revalidate will refresh the stored response in the background, only once at a time per key
+func (r *CacheHandler) revalidate(req *http.Request, key string, rule *Rule) {
+	if _, inflight := r.revalidating.LoadOrStore(key, struct{}{}); inflight {
+		return
+	}
+	// the request context may be canceled right after the stale response is served,
+	// its values are kept, e.g the parsed GraphQL operation
+	bgReq := req.Clone(detachedContext{req.Context()})
+	go func() {
+		defer r.revalidating.Delete(key)
+		if req.GetBody != nil {
+			// the body of the request is already consumed, e.g the POST with the body in the key
+			body, err := req.GetBody()
+			if err != nil {
+				log.Printf("Can't revalidate the stale response, please check. Err: %v\n", err)
+				return
+			}
+			bgReq.Body = body
+		}
+		resp, err := r.sendUpstream(bgReq)
+		if err != nil {
+			log.Printf("Can't revalidate the stale response, please check. Err: %v\n", err)
+			return
+		}
+		defer discardBody(resp)
+		if isUpstreamFailure(resp, nil) {
+			return
+		}
+		r.cacheResponse(bgReq, resp, rule)
+	}()
+}
+
+// detachedContext keeps the values of the parent context, without its deadline and cancellation
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return }
+func (detachedContext) Done() <-chan struct{}                   { return nil }
+func (detachedContext) Err() error                              { return nil }
+
+// isUpstreamFailure will check whether the upstream failed to serve the request
+func isUpstreamFailure(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,140 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bxcodec/httpcache/cache"
+)
+
+// Tag headers
+const (
+	// HeaderSurrogateKey contains the space separated tags of the response
+	HeaderSurrogateKey = "Surrogate-Key"
+	// HeaderCacheTag contains the comma separated tags of the response
+	HeaderCacheTag = "Cache-Tag"
+)
+
+// ResponseTags will return the tags of the response from the Surrogate-Key and Cache-Tag headers
+func ResponseTags(header http.Header) (tags []string) {
+	seen := map[string]bool{}
+	add := func(tag string) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			return
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	for _, v := range header.Values(HeaderSurrogateKey) {
+		for _, tag := range strings.Fields(v) {
+			add(tag)
+		}
+	}
+	for _, v := range header.Values(HeaderCacheTag) {
+		for _, tag := range strings.Split(v, ",") {
+			add(tag)
+		}
+	}
+	return tags
+}
+
+// indexTags will index the key by the response tags, and the tags of the request,
+// if the cache storage support it
+func (r *CacheHandler) indexTags(req *http.Request, key string, header http.Header) {
+	indexer, ok := r.CacheInteractor.(cache.TagIndexer)
+	if !ok {
+		return
+	}
+	tags := ResponseTags(header)
+	if extra := requestTags(req); len(extra) > 0 {
+		tags = dedupTags(append(tags, extra...))
+	}
+	if len(tags) == 0 {
+		return
+	}
+	if err := indexer.AddTags(req.Context(), key, tags); err != nil {
+		log.Printf("Can't index the tags of the response, please check. Err: %v\n", err)
+	}
+}
+
+// PurgeTag will remove all the stored responses tagged with the tag.
+// If the cache storage doesn't implement the cache.TagIndexer, all the stored responses will be
+// examined, which requires the cache storage to implement the cache.KeyLister.
+func (r *CacheHandler) PurgeTag(ctx context.Context, tag string) (purged int, err error) {
+	indexer, ok := r.CacheInteractor.(cache.TagIndexer)
+	if !ok {
+		return r.purgeTagByScan(ctx, tag)
+	}
+
+	keys, err := indexer.KeysByTag(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err = r.CacheInteractor.Delete(key); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, indexer.RemoveTag(ctx, tag)
+}
+
+func (r *CacheHandler) purgeTagByScan(ctx context.Context, tag string) (purged int, err error) {
+	lister, ok := r.CacheInteractor.(cache.KeyLister)
+	if !ok {
+		return 0, cache.ErrNotSupported
+	}
+	keys, err := lister.Keys(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		item, errGet := r.CacheInteractor.Get(key)
+		if errGet != nil || !hasTag(item, tag) {
+			continue
+		}
+		if err = r.CacheInteractor.Delete(key); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func hasTag(item cache.CachedResponse, tag string) bool {
+	for _, t := range itemTags(item) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// itemTags will return the tags of the stored item, from its response headers and its request
+func itemTags(item cache.CachedResponse) []string {
+	var tags []string
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(item.DumpedResponse)), nil)
+	if err == nil {
+		resp.Body.Close() //nolint
+		tags = ResponseTags(resp.Header)
+	}
+	return dedupTags(append(tags, item.Tags...))
+}
+
+func dedupTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	kept := tags[:0]
+	for _, tag := range tags {
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		kept = append(kept, tag)
+	}
+	return kept
+}
@@ -0,0 +1,75 @@
+package httpcache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/disk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseTags(t *testing.T) {
+	header := http.Header{}
+	header.Add(httpcache.HeaderSurrogateKey, "product:1  product:2")
+	header.Add(httpcache.HeaderCacheTag, "product:2, category:3,")
+	require.Equal(t, []string{"product:1", "product:2", "category:3"}, httpcache.ResponseTags(header))
+}
+
+func TestPurgeTag(t *testing.T) {
+	var hits int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set(httpcache.HeaderSurrogateKey, "product:"+r.URL.Query().Get("id")+" product")
+		_, _ = io.WriteString(w, r.URL.String())
+	}))
+	defer upstream.Close()
+
+	inmemClient := &http.Client{}
+	inmemHandler, err := httpcache.NewWithInmemoryCache(inmemClient, true)
+	require.NoError(t, err)
+	diskClient := &http.Client{}
+	diskHandler, err := httpcache.NewWithCustomStorageCache(diskClient, true, disk.NewCache(t.TempDir(), 0))
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		client  *http.Client
+		handler *httpcache.CacheHandler
+	}{
+		"indexed": {client: inmemClient, handler: inmemHandler},
+		"scanned": {client: diskClient, handler: diskHandler},
+	} {
+		t.Run(name, func(t *testing.T) {
+			get := func(id string) {
+				req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, upstream.URL+"/?id="+id, http.NoBody)
+				require.NoError(t, err)
+				resp, err := tc.client.Do(req)
+				require.NoError(t, err)
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			atomic.StoreInt64(&hits, 0)
+			get("1")
+			get("2")
+			get("1")
+			require.EqualValues(t, 2, atomic.LoadInt64(&hits))
+
+			purged, err := tc.handler.PurgeTag(context.TODO(), "product:1")
+			require.NoError(t, err)
+			require.Equal(t, 1, purged)
+
+			get("1")
+			get("2")
+			require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+			purged, err = tc.handler.PurgeTag(context.TODO(), "product")
+			require.NoError(t, err)
+			require.Equal(t, 2, purged)
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package httpcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+)
+
+// DefaultWarmRetryInterval is the interval of re-warming the URLs failed, skipped or without freshness
+const DefaultWarmRetryInterval = time.Minute
+
+// errNotStored is the error of the URL neither stored nor skipped, e.g it matches a bypass rule
+var errNotStored = errors.New("response is not stored")
+
+// WarmOutcome is the outcome of warming a URL
+type WarmOutcome int
+
+// Warm outcomes
+const (
+	// WarmStored means the response is fetched and stored to the cache storage
+	WarmStored WarmOutcome = iota
+	// WarmSkipped means the response is fetched but not cachable, see WarmResult.Reasons and WarmResult.Err
+	WarmSkipped
+	// WarmFailed means the response can't be fetched or stored, see WarmResult.Err
+	WarmFailed
+)
+
+// String will return the string version of the warm outcome
+func (o WarmOutcome) String() string {
+	switch o {
+	case WarmStored:
+		return "stored"
+	case WarmSkipped:
+		return "skipped"
+	case WarmFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// WarmResult is the outcome of warming a URL
+type WarmResult struct {
+	URL        string
+	Outcome    WarmOutcome
+	StatusCode int                   // The status code of the upstream response, zero if it's not fetched
+	Reasons    []cacheControl.Reason // The reasons the response is not cachable, only for WarmSkipped
+	Err        error
+	ExpiresAt  time.Time // The time the stored response become stale, zero if it's unknown
+}
+
+// KeepWarmOptions is the configuration of KeepWarm
+type KeepWarmOptions struct {
+	// Concurrency is the max number of URLs fetched at a time, 1 if zero
+	Concurrency int
+	// Ahead is how long before the expiration the URL is re-warmed, a tenth of the freshness lifetime if zero
+	Ahead time.Duration
+	// RetryInterval is the interval of re-warming the URLs failed, skipped or without freshness,
+	// the DefaultWarmRetryInterval if zero
+	RetryInterval time.Duration
+	// OnResult is called with the outcome of each warming, optional
+	OnResult func(WarmResult)
+}
+
+// eventRecorderKey is the context key of the observer receiving the events of a single request
+type eventRecorderKey struct{}
+
+// eventRecorder records the events of a single request
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (e *eventRecorder) OnEvent(ev Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, ev)
+}
+
+// Warm will fetch the URLs from the upstream through the cache handler, so their responses are stored
+// to the cache storage, e.g before taking traffic. The stored responses are refreshed regardless of
+// their freshness. The results are in the order of the URLs.
+func (r *CacheHandler) Warm(ctx context.Context, urls []string, concurrency int) []WarmResult {
+	i := 0
+	return r.WarmIter(ctx, func() (string, bool) {
+		if i >= len(urls) {
+			return "", false
+		}
+		i++
+		return urls[i-1], true
+	}, concurrency)
+}
+
+// WarmFile will warm the URLs listed in the file, one per line. The empty lines and the lines
+// starting with "#" are ignored.
+func (r *CacheHandler) WarmFile(ctx context.Context, file string, concurrency int) ([]WarmResult, error) {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint
+	return r.WarmReader(ctx, f, concurrency)
+}
+
+// WarmReader will warm the URLs read from the reader, see WarmFile
+func (r *CacheHandler) WarmReader(ctx context.Context, reader io.Reader, concurrency int) ([]WarmResult, error) {
+	scanner := bufio.NewScanner(reader)
+	results := r.WarmIter(ctx, func() (string, bool) {
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				return line, true
+			}
+		}
+		return "", false
+	}, concurrency)
+	return results, scanner.Err()
+}
+
+// WarmIter will warm the URLs returned by the next func until it returns false, see Warm.
+// The next func is never called concurrently.
+func (r *CacheHandler) WarmIter(ctx context.Context, next func() (string, bool), concurrency int) []WarmResult {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var (
+		mu      sync.Mutex
+		results []WarmResult
+		wg      sync.WaitGroup
+	)
+	sem := make(chan struct{}, concurrency)
+	for i := 0; ctx.Err() == nil; i++ {
+		target, ok := next()
+		if !ok {
+			break
+		}
+		mu.Lock()
+		results = append(results, WarmResult{URL: target})
+		mu.Unlock()
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, target string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res := r.warm(ctx, target)
+			mu.Lock()
+			results[i] = res
+			mu.Unlock()
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+// KeepWarm will warm the URLs, then re-warm each of them before its stored response expires,
+// until the ctx is done.
+func (r *CacheHandler) KeepWarm(ctx context.Context, urls []string, opts KeepWarmOptions) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultWarmRetryInterval
+	}
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for _, target := range urls {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			timer := time.NewTimer(0)
+			defer timer.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-timer.C:
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case sem <- struct{}{}:
+				}
+				res := r.warm(ctx, target)
+				<-sem
+				if opts.OnResult != nil {
+					opts.OnResult(res)
+				}
+				timer.Reset(nextWarm(res, opts, time.Now()))
+			}
+		}(target)
+	}
+	wg.Wait()
+}
+
+// nextWarm will return how long to wait before re-warming the URL
+func nextWarm(res WarmResult, opts KeepWarmOptions, now time.Time) time.Duration {
+	if res.Outcome != WarmStored || res.ExpiresAt.IsZero() || !res.ExpiresAt.After(now) {
+		return opts.RetryInterval
+	}
+	lifetime := res.ExpiresAt.Sub(now)
+	ahead := opts.Ahead
+	if ahead <= 0 {
+		ahead = lifetime / 10
+	}
+	if ahead >= lifetime {
+		// re-warming right away would hammer the upstream
+		return lifetime / 2
+	}
+	return lifetime - ahead
+}
+
+// warm will fetch the URL from the upstream, and store its response through the caching path
+func (r *CacheHandler) warm(ctx context.Context, target string) (res WarmResult) {
+	res.URL = target
+	recorder := &eventRecorder{}
+	ctx = context.WithValue(WithForceRefresh(ctx), eventRecorderKey{}, recorder)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, http.NoBody)
+	if err != nil {
+		res.Outcome, res.Err = WarmFailed, err
+		return
+	}
+	resp, err := r.RoundTrip(req)
+	if err != nil {
+		res.Outcome, res.Err = WarmFailed, err
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	res.StatusCode = resp.StatusCode
+
+	res.Outcome, res.Err = WarmSkipped, errNotStored
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	for _, ev := range recorder.events {
+		switch ev.Type {
+		case EventCacheStored:
+			res.Outcome, res.Err = WarmStored, nil
+			res.ExpiresAt = r.storedExpiration(req, ev.Key)
+		case EventCacheSkipped:
+			res.Outcome, res.Reasons, res.Err = WarmSkipped, ev.Reasons, ev.Err
+		case EventStorageError:
+			res.Outcome, res.Err = WarmFailed, ev.Err
+		}
+	}
+	return
+}
+
+// storedExpiration will return the time the stored response become stale, zero if it's unknown
+func (r *CacheHandler) storedExpiration(req *http.Request, key string) time.Time {
+	resp, _, expiresAt, err := r.lookupCachedResponse(req, key)
+	if err != nil {
+		return time.Time{}
+	}
+	resp.Body.Close()
+	return expiresAt
+}
@@ -0,0 +1,89 @@
+package httpcache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bxcodec/httpcache"
+	"github.com/bxcodec/httpcache/cache/inmem"
+	cacheControl "github.com/bxcodec/httpcache/helper/cacheheader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarm(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+
+	client := &http.Client{}
+	handler, err := httpcache.New(client, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}))
+	require.NoError(t, err)
+
+	results := handler.Warm(context.TODO(), []string{
+		upstream.URL + "/products",
+		upstream.URL + "/private",
+		"http://127.0.0.1:0/unreachable",
+		upstream.URL + "/categories",
+	}, 2)
+	require.Len(t, results, 4)
+	require.Equal(t, httpcache.WarmStored, results[0].Outcome)
+	require.Equal(t, http.StatusOK, results[0].StatusCode)
+	require.True(t, results[0].ExpiresAt.After(time.Now()))
+	require.Equal(t, httpcache.WarmSkipped, results[1].Outcome)
+	require.Contains(t, results[1].Reasons, cacheControl.ReasonResponseNoStore)
+	require.Equal(t, httpcache.WarmFailed, results[2].Outcome)
+	require.Error(t, results[2].Err)
+	require.Equal(t, upstream.URL+"/categories", results[3].URL)
+	require.Equal(t, httpcache.WarmStored, results[3].Outcome)
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+	// served from the cache storage
+	_, body := doGet(t, client, upstream.URL+"/products")
+	require.Equal(t, "/products", body)
+	require.EqualValues(t, 3, atomic.LoadInt64(&hits))
+
+	// the stored response is refreshed
+	results, err = handler.WarmReader(context.TODO(), strings.NewReader("# hot endpoints\n\n"+upstream.URL+"/products\n"), 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, httpcache.WarmStored, results[0].Outcome)
+	require.EqualValues(t, 4, atomic.LoadInt64(&hits))
+}
+
+func TestKeepWarm(t *testing.T) {
+	var hits int64
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=1")
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	handler, err := httpcache.New(&http.Client{}, inmem.NewBoundedCache(inmem.BoundedCacheOptions{}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 1500*time.Millisecond)
+	defer cancel()
+	var stored int64
+	handler.KeepWarm(ctx, []string{upstream.URL + "/products"}, httpcache.KeepWarmOptions{
+		Ahead: 500 * time.Millisecond,
+		OnResult: func(res httpcache.WarmResult) {
+			if res.Outcome == httpcache.WarmStored {
+				atomic.AddInt64(&stored, 1)
+			}
+		},
+	})
+	// re-warmed before the stored response expires
+	require.GreaterOrEqual(t, atomic.LoadInt64(&stored), int64(2))
+	require.Equal(t, atomic.LoadInt64(&stored), atomic.LoadInt64(&hits))
+}